@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/soveran/redisurl"
+)
+
+// Backend stores the cached values, and the lists and queues of the progress logs and jobs. RedisBackend keeps them in
+// redis (shared by all the instances of the server) and MemoryBackend in the process. Keys that dont exist return ErrMiss.
+type Backend interface {
+	// Get returns the value of the key
+	Get(key string) ([]byte, error)
+	// Set sets the value of the key. It expires after ttl (or never when ttl is 0)
+	Set(key string, value []byte, ttl time.Duration) error
+	// SetNX sets the value of the key only if it isnt set. It returns true if it was set
+	SetNX(key string, value []byte, ttl time.Duration) (bool, error)
+	// Delete removes the key
+	Delete(key string) error
+	// Incr increments the number in the key (0 when it isnt set) and returns it
+	Incr(key string) (int64, error)
+	// Append adds the value to the end of the list in the key. The list expires after ttl. When ttl is 0, when it expires
+	// isnt changed (a new list doesnt expire).
+	Append(key string, value []byte, ttl time.Duration) error
+	// Range returns the values of the list in the key from start to stop (both included). Negative indexes count from
+	// the end, like redis' LRANGE.
+	Range(key string, start, stop int) ([][]byte, error)
+	// Push adds the value to the front of the queue in the key
+	Push(key string, value []byte) error
+	// Pop removes and returns the value at the back of the queue in the key and adds it to the front of the list in
+	// processing, in one step like redis' BRPOPLPUSH, so that it isnt lost if whoever popped it stops before it is done
+	// with it. It waits at most timeout for one.
+	Pop(key, processing string, timeout time.Duration) ([]byte, error)
+	// Remove removes the value from the list in the key
+	Remove(key string, value []byte) error
+}
+
+// DefaultMemorySize is the max number of keys of the memory backend when CACHE_SIZE isnt set
+const DefaultMemorySize = 10000
+
+// NewBackend returns the backend set by CACHE_BACKEND: "redis" (the default, connecting to REDIS_URL) or "memory",
+// which keeps at most CACHE_SIZE keys. The memory backend isnt shared between instances so it is for running locally
+// (and tests) without redis.
+func NewBackend() Backend {
+	if strings.ToLower(os.Getenv("CACHE_BACKEND")) == "memory" {
+		size, err := strconv.Atoi(os.Getenv("CACHE_SIZE"))
+		if err != nil || size < 1 {
+			size = DefaultMemorySize
+		}
+		return NewMemoryBackend(size)
+	}
+	return NewRedisBackend(&redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redisurl.Connect()
+		},
+	})
+}
+
+// RedisBackend is a Backend that keeps the values in redis
+type RedisBackend struct {
+	Pool *redis.Pool
+}
+
+// NewRedisBackend returns a new RedisBackend using the pool
+func NewRedisBackend(pool *redis.Pool) *RedisBackend {
+	return &RedisBackend{Pool: pool}
+}
+
+// Get returns the value of the key
+func (backend *RedisBackend) Get(key string) ([]byte, error) {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	value, err := redis.Bytes(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+// Set sets the value of the key
+func (backend *RedisBackend) Set(key string, value []byte, ttl time.Duration) error {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	var err error
+	if ttl > 0 {
+		_, err = conn.Do("SET", key, value, "EX", seconds(ttl))
+	} else {
+		_, err = conn.Do("SET", key, value)
+	}
+	return err
+}
+
+// SetNX sets the value of the key only if it isnt set
+func (backend *RedisBackend) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	_, err := redis.String(conn.Do("SET", key, value, "NX", "EX", seconds(ttl)))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes the key
+func (backend *RedisBackend) Delete(key string) error {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DEL", key)
+	return err
+}
+
+// Incr increments the number in the key
+func (backend *RedisBackend) Incr(key string) (int64, error) {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	return redis.Int64(conn.Do("INCR", key))
+}
+
+// Append adds the value to the end of the list in the key
+func (backend *RedisBackend) Append(key string, value []byte, ttl time.Duration) error {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	if ttl <= 0 {
+		// when the list expires isnt changed
+		_, err := conn.Do("RPUSH", key, value)
+		return err
+	}
+	conn.Send("MULTI")
+	conn.Send("RPUSH", key, value)
+	conn.Send("EXPIRE", key, seconds(ttl))
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+// Range returns the values of the list in the key from start to stop
+func (backend *RedisBackend) Range(key string, start, stop int) ([][]byte, error) {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	return redis.ByteSlices(conn.Do("LRANGE", key, start, stop))
+}
+
+// Push adds the value to the front of the queue in the key
+func (backend *RedisBackend) Push(key string, value []byte) error {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("LPUSH", key, value)
+	return err
+}
+
+// Pop removes and returns the value at the back of the queue in the key and adds it to the list in processing
+func (backend *RedisBackend) Pop(key, processing string, timeout time.Duration) ([]byte, error) {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	value, err := redis.Bytes(conn.Do("BRPOPLPUSH", key, processing, seconds(timeout)))
+	if err == redis.ErrNil {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+// Remove removes the value from the list in the key
+func (backend *RedisBackend) Remove(key string, value []byte) error {
+	conn := backend.Pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("LREM", key, 0, value)
+	return err
+}
+
+// seconds returns the duration in (at least 1) seconds. redis doesnt take less
+func seconds(duration time.Duration) int {
+	if duration < time.Second {
+		return 1
+	}
+	return int(duration.Seconds())
+}
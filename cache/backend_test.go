@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/soveran/redisurl"
+)
+
+// testBackend is a backend the Backend tests are run against, with the shortest ttl it takes
+type testBackend struct {
+	name    string
+	backend Backend
+	ttl     time.Duration
+}
+
+// testBackends returns the memory backend, and the redis backend when REDIS_URL is set, so that the tests check that
+// they behave the same
+func testBackends(t *testing.T) []testBackend {
+	backends := []testBackend{{name: "memory", backend: NewMemoryBackend(10), ttl: 20 * time.Millisecond}}
+	if os.Getenv("REDIS_URL") == "" {
+		t.Log("REDIS_URL isnt set. Only testing the memory backend")
+		return backends
+	}
+	// redis doesnt take less than a second
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisurl.Connect() }}
+	return append(backends, testBackend{name: "redis", backend: NewRedisBackend(pool), ttl: time.Second})
+}
+
+func TestBackendAppendKeepsTheExpiry(t *testing.T) {
+	for _, test := range testBackends(t) {
+		key := "test-append-" + time.Now().Format(time.RFC3339Nano)
+		test.backend.Append(key, []byte("a"), test.ttl)
+		// a ttl of 0 doesnt make the list never expire
+		test.backend.Append(key, []byte("b"), 0)
+		if values, _ := test.backend.Range(key, 0, -1); len(values) != 2 {
+			t.Errorf("%s: expected 2 values, got %q", test.name, values)
+		}
+		time.Sleep(test.ttl + test.ttl/2)
+		if values, _ := test.backend.Range(key, 0, -1); len(values) != 0 {
+			t.Errorf("%s: expected the list to have expired, got %q", test.name, values)
+		}
+
+		// a new list appended to with a ttl of 0 doesnt expire
+		test.backend.Append(key, []byte("c"), 0)
+		time.Sleep(test.ttl + test.ttl/2)
+		if values, _ := test.backend.Range(key, 0, -1); len(values) != 1 {
+			t.Errorf("%s: expected the list not to expire, got %q", test.name, values)
+		}
+		test.backend.Delete(key)
+	}
+}
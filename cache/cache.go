@@ -0,0 +1,412 @@
+// Package cache keeps what is fetched from the platforms (tracks, searches, conversions, playlists and tokens) in a Backend (redis
+// or memory). Each kind of value has its own TTL, values that dont exist are cached for a short time too, and the keys
+// are prefixed with the schema version so that bumping Version invalidates everything cached in the old shape.
+//
+// Identical fetches are done once: callers fetching a value that is already being fetched wait for it and share the
+// result, and a lock in the backend makes the other instances of the server wait for it too.
+package cache
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"log"
+	"time"
+	"zoove/errors"
+	"zoove/types"
+
+	"golang.org/x/oauth2"
+)
+
+// Version is the schema version of the cached values. Bump it when types.SingleTrack (or another cached type) changes.
+const Version = "v1"
+
+// ErrMiss is returned when a value isnt cached
+var ErrMiss = goerrors.New("not cached")
+
+// Kind is a kind of cached value
+type Kind string
+
+// The kinds of cached values
+const (
+	Track              Kind = "track"
+	Search             Kind = "search"
+	Album              Kind = "album"
+	Artist             Kind = "artist"
+	Conversion         Kind = "conversion"
+	Playlist           Kind = "playlist"
+	PlaylistConversion Kind = "playlist-conversion"
+	Token              Kind = "token"
+	Overrides          Kind = "overrides" // when a mapping override was last pinned or removed (see OverridesChanged)
+)
+
+// DefaultTTLs is how long each kind of value is cached. Playlists change often so they are only kept for a bit, and
+// spotify tokens expire after an hour.
+var DefaultTTLs = map[Kind]time.Duration{
+	Track:              7 * 24 * time.Hour,
+	Search:             24 * time.Hour,
+	Album:              7 * 24 * time.Hour,
+	Artist:             7 * 24 * time.Hour,
+	Conversion:         24 * time.Hour,
+	Playlist:           10 * time.Minute,
+	PlaylistConversion: 24 * time.Hour, // longer than playlists since they are checked against the playlist's snapshot
+	Token:              50 * time.Minute,
+	Overrides:          24 * time.Hour, // as long as the playlist conversions it is checked against
+}
+
+// ReverseConfidence is the confidence a track found by a text search needs for it to be cached as converting back to the
+// track it was searched for. Below it, the track found might be a different version whose own conversion is another.
+const ReverseConfidence = 0.9
+
+// isrcStrategy is the match strategy of the tracks matched by ISRC (util.MatchStrategyISRC). They are as sure one way as
+// the other. Mappings and overrides are looked up before the cache so the tracks cached are only ever matched by ISRC
+// or a text search.
+const isrcStrategy = "isrc"
+
+// DefaultNegativeTTL is how long a value that wasnt found is cached
+const DefaultNegativeTTL = 10 * time.Minute
+
+// DefaultLockTTL is how long an instance holds the lock on a value it is fetching. The other instances wait for the
+// value that long at most, then fetch it themselves.
+const DefaultLockTTL = 10 * time.Second
+
+// lockPoll is how often an instance waiting for another one checks if the value has been cached
+const lockPoll = 100 * time.Millisecond
+
+// notFound is the value cached for values that dont exist
+const notFound = "!not-found"
+
+// Cache caches values in the backend. A nil Cache (or one without a Backend) caches nothing.
+type Cache struct {
+	Backend     Backend
+	Version     string
+	TTLs        map[Kind]time.Duration
+	NegativeTTL time.Duration
+	LockTTL     time.Duration
+	flights     flights
+}
+
+// New returns a new Cache with the default TTLs
+func New(backend Backend) *Cache {
+	return &Cache{Backend: backend, Version: Version, TTLs: DefaultTTLs, NegativeTTL: DefaultNegativeTTL,
+		LockTTL: DefaultLockTTL}
+}
+
+// Key returns the key of the value of the kind with the id
+func (cache *Cache) Key(kind Kind, id string) string {
+	return fmt.Sprintf("%s-%s-%s", cache.Version, kind, id)
+}
+
+// Get reads the cached value of the kind with the id into out. It returns ErrMiss when it isnt cached and
+// errors.NotFound when it was cached as not found (see SetNotFound).
+func (cache *Cache) Get(kind Kind, id string, out interface{}) error {
+	if cache == nil || cache.Backend == nil {
+		return ErrMiss
+	}
+	value, err := cache.Backend.Get(cache.Key(kind, id))
+	if err == ErrMiss {
+		return ErrMiss
+	}
+	if err != nil {
+		// the value is fetched instead. it is slower but users dont notice the cache is down
+		log.Println("Error getting from cache")
+		log.Println(err)
+		return ErrMiss
+	}
+	if string(value) == notFound {
+		return errors.NotFound
+	}
+	err = json.Unmarshal(value, out)
+	if err != nil {
+		log.Printf("Error deserializing cached %s %s\n", kind, id)
+		log.Println(err)
+		return ErrMiss
+	}
+	return nil
+}
+
+// Set caches the value of the kind with the id for the TTL of the kind
+func (cache *Cache) Set(kind Kind, id string, value interface{}) error {
+	if cache == nil || cache.Backend == nil {
+		return nil
+	}
+	serialized, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return cache.set(cache.Key(kind, id), serialized, cache.TTLs[kind])
+}
+
+// SetNotFound caches that the value of the kind with the id doesnt exist, for NegativeTTL
+func (cache *Cache) SetNotFound(kind Kind, id string) error {
+	if cache == nil || cache.Backend == nil {
+		return nil
+	}
+	return cache.set(cache.Key(kind, id), []byte(notFound), cache.NegativeTTL)
+}
+
+// Delete removes the value of the kind with the id from the cache
+func (cache *Cache) Delete(kind Kind, id string) error {
+	if cache == nil || cache.Backend == nil {
+		return nil
+	}
+	return cache.Backend.Delete(cache.Key(kind, id))
+}
+
+// Track returns the cached track (or search result) of the kind with the id. When it isnt cached, fetch is called and
+// the track it returns is cached. A fetch that returns errors.NotFound is cached as not found.
+func (cache *Cache) Track(kind Kind, id string, fetch func() (*types.SingleTrack, error)) (*types.SingleTrack, error) {
+	track := &types.SingleTrack{}
+	err := cache.load(kind, id, track, func() (interface{}, error) {
+		return fetch()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// Album returns the cached album with the id. When it isnt cached, fetch is called and the album it returns is cached.
+// A fetch that returns errors.NotFound is cached as not found.
+func (cache *Cache) Album(id string, fetch func() (*types.Album, error)) (*types.Album, error) {
+	album := &types.Album{}
+	err := cache.load(Album, id, album, func() (interface{}, error) {
+		return fetch()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// Artist returns the cached artiste with the id. When it isnt cached, fetch is called and the artiste it returns is
+// cached. A fetch that returns errors.NotFound is cached as not found.
+func (cache *Cache) Artist(id string, fetch func() (*types.Artist, error)) (*types.Artist, error) {
+	artist := &types.Artist{}
+	err := cache.load(Artist, id, artist, func() (interface{}, error) {
+		return fetch()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return artist, nil
+}
+
+// Conversion returns the cached track on the target platform that the track was converted to. When it isnt cached,
+// fetch is called to find it and the track it returns is cached. When the match is sure (matched by ISRC or with at
+// least ReverseConfidence) the track is also cached as the conversion of the track found the other way. A track that
+// isnt found (fetch returns errors.NotFound or no track) is cached as not found.
+func (cache *Cache) Conversion(track *types.SingleTrack, target string, fetch func() (*types.SingleTrack, error)) (*types.SingleTrack, error) {
+	find := func() (*types.SingleTrack, error) {
+		found, err := fetch()
+		if err == nil && found == nil {
+			return nil, errors.NotFound
+		}
+		return found, err
+	}
+	if track.ID == "" {
+		return find()
+	}
+	return cache.Track(Conversion, conversionID(track.Platform, track.ID, target), func() (*types.SingleTrack, error) {
+		found, err := find()
+		if err != nil || found.ID == "" {
+			return found, err
+		}
+		if found.MatchStrategy != isrcStrategy && found.Confidence < ReverseConfidence {
+			return found, nil
+		}
+		// the track is the match of the track found, as sure as the other way
+		reverse := *track
+		reverse.MatchStrategy, reverse.Confidence, reverse.Alternatives = found.MatchStrategy, found.Confidence, nil
+		cache.setOrLog(Conversion, conversionID(found.Platform, found.ID, track.Platform), &reverse)
+		return found, nil
+	})
+}
+
+// DeleteConversion removes the cached conversion of the track with the id on the platform to the target platform, and
+// the conversion the other way of the track it was converted to. It is for conversions that were wrong.
+func (cache *Cache) DeleteConversion(platform, id, target string) error {
+	key := conversionID(platform, id, target)
+	found := &types.SingleTrack{}
+	if err := cache.Get(Conversion, key, found); err == nil && found.ID != "" {
+		reverse := &types.SingleTrack{}
+		// the track found might have been cached as the conversion of another track since
+		err = cache.Get(Conversion, conversionID(target, found.ID, platform), reverse)
+		if err == nil && reverse.ID == id {
+			if err := cache.Delete(Conversion, conversionID(target, found.ID, platform)); err != nil {
+				return err
+			}
+		}
+	}
+	return cache.Delete(Conversion, key)
+}
+
+// OverridesChanged returns when a mapping override was last pinned or removed. It is zero when none has been for as
+// long as the playlist conversions are cached.
+func (cache *Cache) OverridesChanged() time.Time {
+	changed := time.Time{}
+	if err := cache.Get(Overrides, "changed", &changed); err != nil {
+		return time.Time{}
+	}
+	return changed
+}
+
+// SetOverridesChanged saves that a mapping override was pinned or removed at the time, so that the conversions from
+// before then are done again (see OverridesChanged).
+func (cache *Cache) SetOverridesChanged(at time.Time) error {
+	return cache.Set(Overrides, "changed", at)
+}
+
+// Playlist returns the cached playlist with the id. When it isnt cached, fetch is called and the playlist it returns
+// is cached. A fetch that returns errors.NotFound is cached as not found.
+func (cache *Cache) Playlist(id string, fetch func() (types.Playlist, error)) (types.Playlist, error) {
+	playlist := types.Playlist{}
+	err := cache.load(Playlist, id, &playlist, func() (interface{}, error) {
+		return fetch()
+	})
+	if err != nil {
+		return types.Playlist{}, err
+	}
+	return playlist, nil
+}
+
+// Token returns the cached token with the id. When it isnt cached, fetch is called and the token it returns is cached.
+func (cache *Cache) Token(id string, fetch func() (*oauth2.Token, error)) (*oauth2.Token, error) {
+	token := &oauth2.Token{}
+	err := cache.Get(Token, id, token)
+	if err == nil && token.AccessToken != "" {
+		return token, nil
+	}
+
+	token, err = fetch()
+	if err != nil {
+		return nil, err
+	}
+	// a token that didnt come with an access token (e.g bad credentials) isnt cached
+	if token.AccessToken != "" {
+		cache.setOrLog(Token, id, token)
+	}
+	return token, nil
+}
+
+// load reads the cached value of the kind with the id into out. When it isnt cached, it is fetched once for all the
+// callers that want it at the same time (see fetch). Each caller gets its own copy of the value.
+func (cache *Cache) load(kind Kind, id string, out interface{}, fetch func() (interface{}, error)) error {
+	var value []byte
+	var err error
+	if cache == nil || cache.Backend == nil {
+		value, err = fetchValue(fetch)
+	} else {
+		err = cache.Get(kind, id, out)
+		if err != ErrMiss {
+			return err
+		}
+		value, err = cache.flights.do(cache.Key(kind, id), func() ([]byte, error) {
+			return cache.fetch(kind, id, fetch)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, out)
+}
+
+// fetch fetches and caches the value of the kind with the id, holding its lock. When another instance holds it, fetch
+// waits for that instance to cache the value instead.
+func (cache *Cache) fetch(kind Kind, id string, fetch func() (interface{}, error)) ([]byte, error) {
+	key := cache.Key(kind, id)
+	lock := fmt.Sprintf("%s-lock", key)
+	locked, err := cache.Backend.SetNX(lock, []byte("1"), cache.LockTTL)
+	if err != nil {
+		// like when reading, a cache that is down only makes it slower
+		log.Printf("Error locking %s %s\n", kind, id)
+		log.Println(err)
+	}
+	if err == nil && !locked {
+		value, err := cache.wait(key, lock)
+		if err != ErrMiss {
+			return value, err
+		}
+		// the other instance failed (or is too slow) so it is fetched here
+	}
+	if locked {
+		defer func() {
+			if err := cache.Backend.Delete(lock); err != nil {
+				log.Printf("Error unlocking %s %s\n", kind, id)
+				log.Println(err)
+			}
+		}()
+	}
+
+	value, err := fetchValue(fetch)
+	if err == errors.NotFound {
+		cache.setOrLog(kind, id, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = cache.set(key, value, cache.TTLs[kind])
+	if err != nil {
+		log.Printf("Error caching %s %s\n", kind, id)
+		log.Println(err)
+	}
+	return value, nil
+}
+
+// wait waits for the value in the key to be cached by the instance holding the lock. It returns ErrMiss when the lock
+// is released (or expires) without it being cached.
+func (cache *Cache) wait(key, lock string) ([]byte, error) {
+	deadline := time.Now().Add(cache.LockTTL)
+	for time.Now().Before(deadline) {
+		time.Sleep(lockPoll)
+		// the lock is checked before the key so a value cached right before the lock was released isnt missed
+		_, unlocked := cache.Backend.Get(lock)
+		value, err := cache.Backend.Get(key)
+		if err == nil {
+			if string(value) == notFound {
+				return nil, errors.NotFound
+			}
+			return value, nil
+		}
+		if err != ErrMiss || unlocked != nil {
+			return nil, ErrMiss
+		}
+	}
+	return nil, ErrMiss
+}
+
+// fetchValue calls fetch and serializes the value it returns
+func fetchValue(fetch func() (interface{}, error)) ([]byte, error) {
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// setOrLog caches the value (or that it wasnt found when it is nil) and logs the error if it couldnt be cached. It isnt
+// crucial so it doesnt fail the request.
+func (cache *Cache) setOrLog(kind Kind, id string, value interface{}) {
+	var err error
+	if value == nil {
+		err = cache.SetNotFound(kind, id)
+	} else {
+		err = cache.Set(kind, id, value)
+	}
+	if err != nil {
+		log.Printf("Error caching %s %s\n", kind, id)
+		log.Println(err)
+	}
+}
+
+// conversionID returns the id of the conversion of the track with the id on the platform to the target platform
+func conversionID(platform, id, target string) string {
+	return fmt.Sprintf("%s-%s-%s", platform, id, target)
+}
+
+func (cache *Cache) set(key string, value []byte, ttl time.Duration) error {
+	if cache == nil || cache.Backend == nil {
+		return nil
+	}
+	return cache.Backend.Set(key, value, ttl)
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"zoove/errors"
+	"zoove/types"
+)
+
+// fetchTrack returns a fetch that counts its calls and takes a bit, so concurrent callers overlap
+func fetchTrack(calls *int32, err error) func() (*types.SingleTrack, error) {
+	return func() (*types.SingleTrack, error) {
+		atomic.AddInt32(calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		if err != nil {
+			return nil, err
+		}
+		return &types.SingleTrack{ID: "1", Title: "Runaway"}, nil
+	}
+}
+
+// getConcurrently calls Track for the same id from 20 goroutines (spread over the caches) and returns the errors
+func getConcurrently(t *testing.T, caches []*Cache, fetch func() (*types.SingleTrack, error)) []error {
+	var wait sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			track, err := caches[i%len(caches)].Track(Track, "deezer-1", fetch)
+			if err == nil && track.Title != "Runaway" {
+				t.Errorf("expected the fetched track, got %v", track)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wait.Wait()
+	return errs
+}
+
+func TestTrackCoalescesFetches(t *testing.T) {
+	var calls int32
+	for _, err := range getConcurrently(t, []*Cache{New(NewMemoryBackend(10))}, fetchTrack(&calls, nil)) {
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the track to be fetched once, got %d", calls)
+	}
+}
+
+func TestTrackRetriesACancelledFetch(t *testing.T) {
+	store := New(NewMemoryBackend(10))
+	var calls int32
+	started := make(chan struct{})
+	cancelled := make(chan error)
+	go func() {
+		_, err := store.Track(Track, "deezer-1", func() (*types.SingleTrack, error) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			// the request that started the fetch has ended
+			return nil, context.Canceled
+		})
+		cancelled <- err
+	}()
+	<-started
+
+	track, err := store.Track(Track, "deezer-1", fetchTrack(&calls, nil))
+	if err != nil || track.Title != "Runaway" {
+		t.Errorf("expected the waiting caller to fetch the track itself, got %v (%v)", track, err)
+	}
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("expected the cancelled caller to get its error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the track to be fetched again once, got %d", calls)
+	}
+}
+
+func TestTrackCoalescesFetchesAcrossInstances(t *testing.T) {
+	// each cache is a server instance, sharing the backend like they share redis
+	backend := NewMemoryBackend(10)
+	caches := []*Cache{New(backend), New(backend), New(backend)}
+	var calls int32
+	getConcurrently(t, caches, fetchTrack(&calls, nil))
+	if calls != 1 {
+		t.Errorf("expected the track to be fetched once, got %d", calls)
+	}
+	if _, err := backend.Get(caches[0].Key(Track, "deezer-1") + "-lock"); err != ErrMiss {
+		t.Errorf("expected the lock to be released, got %v", err)
+	}
+}
+
+func TestTrackSharesNotFound(t *testing.T) {
+	backend := NewMemoryBackend(10)
+	var calls int32
+	for _, err := range getConcurrently(t, []*Cache{New(backend), New(backend)}, fetchTrack(&calls, errors.NotFound)) {
+		if err != errors.NotFound {
+			t.Errorf("expected errors.NotFound, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the track to be fetched once, got %d", calls)
+	}
+}
+
+func TestTrackFetchesWhenTheLockExpires(t *testing.T) {
+	backend := NewMemoryBackend(10)
+	cache := New(backend)
+	cache.LockTTL = 200 * time.Millisecond
+	// another instance holds the lock but never caches the track
+	backend.SetNX(cache.Key(Track, "deezer-1")+"-lock", []byte("1"), cache.LockTTL)
+
+	var calls int32
+	track, err := cache.Track(Track, "deezer-1", fetchTrack(&calls, nil))
+	if err != nil || track.Title != "Runaway" {
+		t.Fatalf("expected the fetched track, got %v (%v)", track, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the track to be fetched once, got %d", calls)
+	}
+}
+
+func TestConversionIsCachedBothWays(t *testing.T) {
+	cache := New(NewMemoryBackend(10))
+	source := &types.SingleTrack{ID: "1", Platform: "deezer", Title: "Runaway"}
+	var calls int32
+	search := func() (*types.SingleTrack, error) {
+		atomic.AddInt32(&calls, 1)
+		return &types.SingleTrack{ID: "a", Platform: "spotify", Title: "Runaway", MatchStrategy: "isrc", Confidence: 1}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		found, err := cache.Conversion(source, "spotify", search)
+		if err != nil || found.ID != "a" {
+			t.Fatalf("expected the spotify track, got %v (%v)", found, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the track to be searched once, got %d", calls)
+	}
+
+	found, err := cache.Conversion(&types.SingleTrack{ID: "a", Platform: "spotify"}, "deezer", search)
+	if err != nil || found.ID != "1" || found.MatchStrategy != "isrc" {
+		t.Fatalf("expected the deezer track matched by isrc, got %v (%v)", found, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the track not to be searched the other way, got %d searches", calls)
+	}
+}
+
+func TestConversionOfATextSearchIsntCachedTheOtherWay(t *testing.T) {
+	cache := New(NewMemoryBackend(10))
+	source := &types.SingleTrack{ID: "1", Platform: "deezer", Title: "Runaway"}
+	var calls int32
+	search := func() (*types.SingleTrack, error) {
+		atomic.AddInt32(&calls, 1)
+		return &types.SingleTrack{ID: "a", Platform: "spotify", Title: "Runaway (Live)", MatchStrategy: "search", Confidence: 0.8}, nil
+	}
+	if _, err := cache.Conversion(source, "spotify", search); err != nil {
+		t.Fatal(err)
+	}
+
+	reverse := &types.SingleTrack{}
+	if err := cache.Get(Conversion, conversionID("spotify", "a", "deezer"), reverse); err != ErrMiss {
+		t.Errorf("expected no conversion the other way, got %v (%v)", reverse, err)
+	}
+}
+
+func TestDeleteConversion(t *testing.T) {
+	cache := New(NewMemoryBackend(10))
+	source := &types.SingleTrack{ID: "1", Platform: "deezer"}
+	cache.Conversion(source, "spotify", func() (*types.SingleTrack, error) {
+		return &types.SingleTrack{ID: "a", Platform: "spotify", MatchStrategy: "isrc", Confidence: 1}, nil
+	})
+
+	if err := cache.DeleteConversion("deezer", "1", "spotify"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{conversionID("deezer", "1", "spotify"), conversionID("spotify", "a", "deezer")} {
+		if err := cache.Get(Conversion, id, &types.SingleTrack{}); err != ErrMiss {
+			t.Errorf("expected %s to be removed, got %v", id, err)
+		}
+	}
+}
+
+func TestConversionCachesNotFound(t *testing.T) {
+	cache := New(NewMemoryBackend(10))
+	source := &types.SingleTrack{ID: "1", Platform: "deezer"}
+	var calls int32
+	search := func() (*types.SingleTrack, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, nil
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Conversion(source, "spotify", search); err != errors.NotFound {
+			t.Errorf("expected errors.NotFound, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the track to be searched once, got %d", calls)
+	}
+}
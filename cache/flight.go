@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	goerrors "errors"
+	"sync"
+)
+
+// flight is a fetch in progress. done is closed when it has finished
+type flight struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// flights coalesces identical fetches: while a key is being fetched, the other callers fetching it wait for it and get
+// the same result instead of fetching it again.
+type flights struct {
+	mutex    sync.Mutex
+	inflight map[string]*flight
+}
+
+// do calls fetch for the key, unless it is already being fetched. Then it waits for that fetch and returns its result.
+// A fetch that was cancelled (e.g the request of the caller that started it ended) is only the result of that caller,
+// the callers waiting for it try again with their own fetch.
+func (flights *flights) do(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	for {
+		flights.mutex.Lock()
+		if flights.inflight == nil {
+			flights.inflight = map[string]*flight{}
+		}
+		if current, ok := flights.inflight[key]; ok {
+			flights.mutex.Unlock()
+			<-current.done
+			if cancelled(current.err) {
+				continue
+			}
+			return current.value, current.err
+		}
+		current := &flight{done: make(chan struct{})}
+		flights.inflight[key] = current
+		flights.mutex.Unlock()
+		return flights.run(key, current, fetch)
+	}
+}
+
+// run calls fetch for the flight of the key and then lets the callers waiting for it know
+func (flights *flights) run(key string, current *flight, fetch func() ([]byte, error)) ([]byte, error) {
+	defer func() {
+		flights.mutex.Lock()
+		delete(flights.inflight, key)
+		flights.mutex.Unlock()
+		close(current.done)
+	}()
+	current.value, current.err = fetch()
+	return current.value, current.err
+}
+
+// cancelled returns true if the error is the error of a context that was cancelled or timed out
+func cancelled(err error) bool {
+	return goerrors.Is(err, context.Canceled) || goerrors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryBackend is a Backend that keeps the values in the process. When it has more than Size cached values, the least
+// recently used ones are removed. Lists (queues and logs), locks and counters arent cached values so they are never
+// removed that way, only when they expire.
+type MemoryBackend struct {
+	Size    int
+	mutex   sync.Mutex
+	entries map[string]*memoryEntry
+	// recent has the entries that can be evicted, from the most to the least recently used
+	recent *list.List
+	// pushed is closed (and replaced) when a value is pushed to a queue, to wake up the Pops waiting for one
+	pushed chan struct{}
+}
+
+// memoryEntry is a key of the memory backend. It has either a value or a list
+type memoryEntry struct {
+	key     string
+	value   []byte
+	list    [][]byte
+	expires time.Time
+	// element is the entry in recent, nil when the entry cant be evicted
+	element *list.Element
+}
+
+// NewMemoryBackend returns a new MemoryBackend that keeps at most size keys
+func NewMemoryBackend(size int) *MemoryBackend {
+	return &MemoryBackend{Size: size, entries: map[string]*memoryEntry{}, recent: list.New(), pushed: make(chan struct{})}
+}
+
+// Get returns the value of the key
+func (backend *MemoryBackend) Get(key string) ([]byte, error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	entry := backend.get(key)
+	if entry == nil || entry.value == nil {
+		return nil, ErrMiss
+	}
+	return entry.value, nil
+}
+
+// Set sets the value of the key
+func (backend *MemoryBackend) Set(key string, value []byte, ttl time.Duration) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	backend.set(key, value, ttl, true)
+	return nil
+}
+
+// SetNX sets the value of the key only if it isnt set
+func (backend *MemoryBackend) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	if backend.get(key) != nil {
+		return false, nil
+	}
+	// SetNX is used for locks, which are lost if they are evicted
+	backend.set(key, value, ttl, false)
+	return true, nil
+}
+
+// Delete removes the key
+func (backend *MemoryBackend) Delete(key string) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	if entry, ok := backend.entries[key]; ok {
+		backend.remove(entry)
+	}
+	return nil
+}
+
+// Incr increments the number in the key
+func (backend *MemoryBackend) Incr(key string) (int64, error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	var number int64
+	entry := backend.get(key)
+	if entry != nil {
+		var err error
+		number, err = strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+	}
+	number++
+	value := []byte(strconv.FormatInt(number, 10))
+	if entry != nil {
+		// like redis, incrementing a key doesnt change when it expires
+		entry.value = value
+		return number, nil
+	}
+	backend.set(key, value, 0, false)
+	return number, nil
+}
+
+// Append adds the value to the end of the list in the key
+func (backend *MemoryBackend) Append(key string, value []byte, ttl time.Duration) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	entry := backend.list(key)
+	entry.list = append(entry.list, value)
+	// like redis, a ttl of 0 leaves when the list expires as it is
+	if ttl > 0 {
+		entry.expires = expiry(ttl)
+	}
+	return nil
+}
+
+// Range returns the values of the list in the key from start to stop
+func (backend *MemoryBackend) Range(key string, start, stop int) ([][]byte, error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	entry := backend.get(key)
+	if entry == nil {
+		return nil, nil
+	}
+	length := len(entry.list)
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop {
+		return nil, nil
+	}
+	values := make([][]byte, stop-start+1)
+	copy(values, entry.list[start:stop+1])
+	return values, nil
+}
+
+// Push adds the value to the front of the queue in the key
+func (backend *MemoryBackend) Push(key string, value []byte) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	entry := backend.list(key)
+	entry.list = append([][]byte{value}, entry.list...)
+	close(backend.pushed)
+	backend.pushed = make(chan struct{})
+	return nil
+}
+
+// Pop removes and returns the value at the back of the queue in the key and adds it to the list in processing
+func (backend *MemoryBackend) Pop(key, processing string, timeout time.Duration) ([]byte, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		backend.mutex.Lock()
+		entry := backend.get(key)
+		if entry != nil && len(entry.list) > 0 {
+			last := len(entry.list) - 1
+			value := entry.list[last]
+			entry.list = entry.list[:last]
+			popped := backend.list(processing)
+			popped.list = append([][]byte{value}, popped.list...)
+			backend.mutex.Unlock()
+			return value, nil
+		}
+		pushed := backend.pushed
+		backend.mutex.Unlock()
+
+		select {
+		case <-pushed:
+		case <-timer.C:
+			return nil, ErrMiss
+		}
+	}
+}
+
+// Remove removes the value from the list in the key
+func (backend *MemoryBackend) Remove(key string, value []byte) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	entry := backend.get(key)
+	if entry == nil {
+		return nil
+	}
+	kept := entry.list[:0]
+	for _, item := range entry.list {
+		if !bytes.Equal(item, value) {
+			kept = append(kept, item)
+		}
+	}
+	entry.list = kept
+	return nil
+}
+
+// get returns the entry of the key (nil when it isnt set or has expired) and marks it as recently used
+func (backend *MemoryBackend) get(key string) *memoryEntry {
+	entry, ok := backend.entries[key]
+	if !ok {
+		return nil
+	}
+	if entry.expired(time.Now()) {
+		backend.remove(entry)
+		return nil
+	}
+	if entry.element != nil {
+		backend.recent.MoveToFront(entry.element)
+	}
+	return entry
+}
+
+// set sets the value of the key, replacing what the key had. The key is evicted when it is the least recently used
+// one if evictable is true.
+func (backend *MemoryBackend) set(key string, value []byte, ttl time.Duration, evictable bool) {
+	if entry, ok := backend.entries[key]; ok {
+		backend.remove(entry)
+	}
+	backend.add(&memoryEntry{key: key, value: value, expires: expiry(ttl)}, evictable)
+}
+
+// list returns the entry of the list in the key, adding it if it isnt set
+func (backend *MemoryBackend) list(key string) *memoryEntry {
+	if entry := backend.get(key); entry != nil {
+		return entry
+	}
+	entry := &memoryEntry{key: key}
+	backend.add(entry, false)
+	return entry
+}
+
+// add adds the entry and removes the least recently used entries when there are more than Size that can be evicted.
+// When there are more than Size entries that cant be, the ones that have expired are removed since they might never be
+// read again.
+func (backend *MemoryBackend) add(entry *memoryEntry, evictable bool) {
+	backend.entries[entry.key] = entry
+	if evictable {
+		entry.element = backend.recent.PushFront(entry)
+		for backend.recent.Len() > backend.Size {
+			backend.remove(backend.recent.Back().Value.(*memoryEntry))
+		}
+		return
+	}
+	if len(backend.entries)-backend.recent.Len() > backend.Size {
+		now := time.Now()
+		for _, kept := range backend.entries {
+			if kept.element == nil && kept.expired(now) {
+				backend.remove(kept)
+			}
+		}
+	}
+}
+
+func (backend *MemoryBackend) remove(entry *memoryEntry) {
+	if entry.element != nil {
+		backend.recent.Remove(entry.element)
+	}
+	delete(backend.entries, entry.key)
+}
+
+// expired returns true if the entry has expired at now
+func (entry *memoryEntry) expired(now time.Time) bool {
+	return !entry.expires.IsZero() && now.After(entry.expires)
+}
+
+// expiry returns when a key set now with the ttl expires (zero when it doesnt)
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackendEvictsLeastRecentlyUsed(t *testing.T) {
+	backend := NewMemoryBackend(2)
+	backend.Set("a", []byte("1"), 0)
+	backend.Set("b", []byte("2"), 0)
+	// a is now used more recently than b
+	backend.Get("a")
+	backend.Set("c", []byte("3"), 0)
+
+	if _, err := backend.Get("b"); err != ErrMiss {
+		t.Errorf("expected b to be evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := backend.Get(key); err != nil {
+			t.Errorf("expected %s to be kept, got %v", key, err)
+		}
+	}
+}
+
+func TestMemoryBackendExpires(t *testing.T) {
+	backend := NewMemoryBackend(10)
+	backend.Set("key", []byte("value"), 10*time.Millisecond)
+	if _, err := backend.Get("key"); err != nil {
+		t.Fatalf("expected key to be set, got %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := backend.Get("key"); err != ErrMiss {
+		t.Errorf("expected key to have expired, got %v", err)
+	}
+
+	set, _ := backend.SetNX("key", []byte("value"), time.Minute)
+	if !set {
+		t.Errorf("expected SetNX to set an expired key")
+	}
+	set, _ = backend.SetNX("key", []byte("other"), time.Minute)
+	if set {
+		t.Errorf("expected SetNX not to set a key that is set")
+	}
+}
+
+func TestMemoryBackendIncr(t *testing.T) {
+	backend := NewMemoryBackend(10)
+	for expected := int64(1); expected <= 3; expected++ {
+		count, err := backend.Incr("count")
+		if err != nil || count != expected {
+			t.Errorf("expected %d, got %d (%v)", expected, count, err)
+		}
+	}
+}
+
+func TestMemoryBackendRange(t *testing.T) {
+	backend := NewMemoryBackend(10)
+	for _, value := range []string{"a", "b", "c", "d"} {
+		backend.Append("list", []byte(value), time.Minute)
+	}
+
+	tests := []struct {
+		start, stop int
+		expected    string
+	}{
+		{0, -1, "abcd"},
+		{1, 2, "bc"},
+		{-1, -1, "d"},
+		{2, 10, "cd"},
+		{4, -1, ""},
+	}
+	for _, test := range tests {
+		values, err := backend.Range("list", test.start, test.stop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, value := range values {
+			got = append(got, string(value))
+		}
+		if strings.Join(got, "") != test.expected {
+			t.Errorf("Range(%d, %d): expected %q, got %q", test.start, test.stop, test.expected, strings.Join(got, ""))
+		}
+	}
+
+	values, _ := backend.Range("missing", 0, -1)
+	if len(values) != 0 {
+		t.Errorf("expected no values for a missing list, got %d", len(values))
+	}
+}
+
+func TestMemoryBackendPop(t *testing.T) {
+	backend := NewMemoryBackend(10)
+	backend.Push("queue", []byte("first"))
+	backend.Push("queue", []byte("second"))
+	for _, expected := range []string{"first", "second"} {
+		value, err := backend.Pop("queue", "processing", time.Second)
+		if err != nil || string(value) != expected {
+			t.Errorf("expected %s, got %s (%v)", expected, value, err)
+		}
+	}
+	// the popped values are kept in processing until they are removed
+	processing, _ := backend.Range("processing", 0, -1)
+	if len(processing) != 2 || string(processing[0]) != "second" {
+		t.Errorf("expected the popped values in processing (last popped first), got %q", processing)
+	}
+	backend.Remove("processing", []byte("first"))
+	processing, _ = backend.Range("processing", 0, -1)
+	if len(processing) != 1 || string(processing[0]) != "second" {
+		t.Errorf("expected only second in processing, got %q", processing)
+	}
+
+	if _, err := backend.Pop("queue", "processing", 10*time.Millisecond); err != ErrMiss {
+		t.Errorf("expected an empty queue to time out, got %v", err)
+	}
+
+	// a pop waits for a value to be pushed
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		backend.Push("queue", []byte("late"))
+	}()
+	value, err := backend.Pop("queue", "processing", time.Second)
+	if err != nil || string(value) != "late" {
+		t.Errorf("expected late, got %s (%v)", value, err)
+	}
+}
+
+func TestMemoryBackendDoesntEvictListsAndLocks(t *testing.T) {
+	backend := NewMemoryBackend(1)
+	backend.Push("queue", []byte("job"))
+	backend.Append("log", []byte("event"), time.Minute)
+	backend.SetNX("lock", []byte("1"), time.Minute)
+	backend.Set("a", []byte("1"), 0)
+	backend.Set("b", []byte("2"), 0)
+
+	if _, err := backend.Get("a"); err != ErrMiss {
+		t.Errorf("expected a to be evicted, got %v", err)
+	}
+	if value, err := backend.Pop("queue", "processing", time.Millisecond); err != nil || string(value) != "job" {
+		t.Errorf("expected the queue to be kept, got %q (%v)", value, err)
+	}
+	if values, _ := backend.Range("log", 0, -1); len(values) != 1 {
+		t.Errorf("expected the log to be kept, got %q", values)
+	}
+	if set, _ := backend.SetNX("lock", []byte("1"), time.Minute); set {
+		t.Errorf("expected the lock to be kept")
+	}
+}
@@ -1,30 +1,31 @@
 package controllers
 
 import (
+	goerrors "errors"
+	"fmt"
 	"log"
+	"zoove/cache"
+	"zoove/converter"
 	"zoove/errors"
 	"zoove/platforms"
+	"zoove/progress"
 	"zoove/types"
 	"zoove/util"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gomodule/redigo/redis"
-	"github.com/soveran/redisurl"
 )
 
 type Jaeger struct {
-	Pool *redis.Pool
+	Cache     *cache.Cache
+	Converter *converter.Converter
+	Progress  *progress.Log
+	Resolver  *util.LinkResolver
 }
 
-// NewJaeger returns a new jaeger (tsk tsk)
-func NewJaeger(pool *redis.Pool) *Jaeger {
-	pool = &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			// log.Println(os.Getenv("REDIS_URL"))
-			return redisurl.Connect()
-		},
-	}
-	return &Jaeger{Pool: pool}
+// NewJaeger returns a new jaeger (tsk tsk). The converter is shared with the other handlers so that they all keep to the
+// same limits on the platforms.
+func NewJaeger(store *cache.Cache, converter *converter.Converter, resolver *util.LinkResolver) *Jaeger {
+	return &Jaeger{Cache: store, Converter: converter, Progress: progress.NewLog(store.Backend), Resolver: resolver}
 }
 
 // JaegerHandler is the handler for finding tracks on other platforms from one. Using Jaeger for loss of words lol
@@ -49,82 +50,30 @@ func (jaeger *Jaeger) JaegerHandler(ctx *fiber.Ctx) error {
 	==> If deezer is empty, return 404 but dont trigger other platforms
 	**/
 
-	var tracks = [][]types.SingleTrack{}
-	deezerTracks := []types.SingleTrack{}
-	spotifyTracks := []types.SingleTrack{}
-
-	if extracted.Host == util.HostDeezer {
-		track, err := platforms.HostDeezerGetSingleTrack(extracted.ID, jaeger.Pool)
-		if err != nil {
-			log.Println("Error getting the track from Deezer")
-			log.Println(err)
-			if err == errors.NotFound {
-				log.Println("Track does not exist on deezer")
-				return util.NotFound(ctx)
-			}
-		}
-		search := platforms.NewTrackToSearch(track.Title, track.Artistes[0], jaeger.Pool)
-		spot, err := search.HostSpotifySearchTrack()
-		if err != nil {
-			if err == errors.NotFound {
-				spot = nil
-			}
-			log.Println("Error fetching spotify search")
-			log.Println(err)
-		}
-		track.ReleaseDate = spot.ReleaseDate
-		deezerTracks = append(deezerTracks, *track)
-		spotifyTracks = append(spotifyTracks, *spot)
-		// tracks = append(tracks, track, spot)
-	} else if extracted.Host == util.HostSpotify {
-		track, err := platforms.HostSpotifyGetSingleTrack(extracted.ID, jaeger.Pool)
-		if err != nil {
-			log.Println("Error getting the track from Spotify")
-			log.Println(err)
-			if err == errors.NotFound {
-				log.Println("Track does not exist on spotify")
-				return util.NotFound(ctx)
-			}
-		}
-		search := platforms.NewTrackToSearch(track.Title, track.Artistes[0], jaeger.Pool)
-		deez, err := search.HostDeezerSearchTrack()
-		if err != nil {
-			log.Println("Error getting deezer song")
-			log.Println(err)
-		}
-		// this is because spotify always has release date. but now, remember that we're currently
-		// checking if track has been cached. We're still leaving this 'cos its caching our calls
-		// right now. and thats what we need. but since relasedate real value can be gotten here, simply
-		// using the value here.
-		deez.ReleaseDate = track.ReleaseDate
-		// tracks = append(tracks, track, deez)
-		deezerTracks = append(deezerTracks, *deez)
-		spotifyTracks = append(spotifyTracks, *track)
+	source, ok := platforms.Get(extracted.Host)
+	if !ok {
+		log.Println("Oops! Not a valid host")
+		return util.NotImplementedError(ctx, nil)
 	}
 
-	conn := jaeger.Pool.Get()
-	defer conn.Close()
-
-	_, err := redis.String(conn.Do("GET", util.RedisSearchesKey))
+	// the converter looks up the saved mappings and overrides first and keeps to the limits on the platforms
+	conversion, err := jaeger.Converter.ConvertTrack(ctx.Context(), source, extracted.ID)
 	if err != nil {
-		log.Println("Search counter does not exist.")
+		log.Printf("Error getting the track from %s\n", source.Name())
 		log.Println(err)
-		if err == redis.ErrNil {
-			_, err := redis.String(conn.Do("SET", util.RedisSearchesKey, "1"))
-			if err != nil {
-				log.Println("Error saving key into redis")
-				log.Println(err)
-			}
+		if goerrors.Is(err, errors.NotFound) {
+			log.Printf("Track does not exist on %s\n", source.Name())
+			return util.NotFound(ctx)
 		}
+		return util.InternalServerError(ctx, err)
 	}
 
-	searchesCount, err := redis.Int(conn.Do("INCR", util.RedisSearchesKey))
-	if err != nil {
-		log.Println("Error incrememnting redis key")
-		log.Println(err)
-	}
+	searchesCount := util.IncrementSearches(jaeger.Cache.Backend)
 
-	tracks = append(tracks, deezerTracks, spotifyTracks)
+	var tracks = [][]types.SingleTrack{}
+	for _, track := range converter.Tracks(conversion) {
+		tracks = append(tracks, []types.SingleTrack{track})
+	}
 	log.Printf("Searches count is: %d", searchesCount)
 	return util.RequestOk(ctx, tracks)
 }
@@ -141,50 +90,100 @@ func (jaeger *Jaeger) ConvertPlaylist(ctx *fiber.Ctx) error {
 	extracted := ctx.Locals("extractedInfo").(*types.ExtractedInfo)
 	// log.Printf("Extracted issues: %#v", extracted)
 
-	playlist := &types.Playlist{}
-	if extracted.Host == util.HostDeezer {
-		deezerPlaylist, err := platforms.HostDeezerFetchPlaylistTracks(extracted.ID, jaeger.Pool)
-		if err != nil {
-			log.Printf("Error getting user playlist: %s", err.Error())
-			util.InternalServerError(ctx, err)
-		}
-		playlist = &deezerPlaylist
-	} else if extracted.Host == util.HostSpotify {
-		spotifyPlaylist, err := platforms.HostSpotifyFetchPlaylistTracks(extracted.ID, jaeger.Pool)
-		if err != nil {
-			log.Printf("Error getting spotify playlist: %s", err.Error())
-			return util.InternalServerError(ctx, err)
+	source, ok := platforms.Get(extracted.Host)
+	if !ok {
+		log.Println("Oops! Not a valid host")
+		return util.NotImplementedError(ctx, nil)
+	}
+
+	conversion, err := jaeger.Converter.ConvertPlaylist(ctx.Context(), source, extracted.ID)
+	if err != nil {
+		log.Printf("Error getting %s playlist: %s", source.Name(), err.Error())
+		return util.InternalServerError(ctx, err)
+	}
+	log.Printf("Converted %d tracks of %s playlist in %dms", len(conversion.Tracks), source.Name(), conversion.TookMs)
+	return util.RequestOk(ctx, conversion)
+}
+
+// ConvertAlbum returns the album (and its tracks) on every platform from the album on one.
+func (jaeger *Jaeger) ConvertAlbum(ctx *fiber.Ctx) error {
+	extracted := ctx.Locals("extractedInfo").(*types.ExtractedInfo)
+	source, ok := platforms.Get(extracted.Host)
+	if !ok || extracted.Type != "album" {
+		log.Println("Oops! Not a valid album URL")
+		return util.NotImplementedError(ctx, nil)
+	}
+
+	conversion, err := jaeger.Converter.ConvertAlbum(ctx.Context(), source, extracted.ID)
+	if err != nil {
+		log.Printf("Error converting %s album: %s", source.Name(), err.Error())
+		if err == errors.NotFound {
+			return util.NotFound(ctx)
 		}
-		// log.Printf("\nFetched playlist is: %#v\n", spotifyPlaylist)
-		playlist = &spotifyPlaylist
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, conversion)
+}
+
+// ConvertArtist returns the artiste (and their top tracks) on every platform from the artiste on one.
+func (jaeger *Jaeger) ConvertArtist(ctx *fiber.Ctx) error {
+	extracted := ctx.Locals("extractedInfo").(*types.ExtractedInfo)
+	source, ok := platforms.Get(extracted.Host)
+	if !ok || extracted.Type != "artist" {
+		log.Println("Oops! Not a valid artist URL")
+		return util.NotImplementedError(ctx, nil)
 	}
 
-	outputs := [][]types.SingleTrack{}
-	deezerPlaylist := []types.SingleTrack{}
-	spotifPlaylist := []types.SingleTrack{}
-	spotChan := make(chan *types.SingleTrack)
-	deezChan := make(chan *types.SingleTrack)
-
-	for _, singleTrack := range playlist.Tracks {
-		search := platforms.NewTrackToSearch(singleTrack.Title, singleTrack.Artistes[0], jaeger.Pool)
-		go search.HostDeezerSearchTrackChan(deezChan)
-		deezerTrack := <-deezChan
-		if deezerTrack == nil {
-			continue
+	conversion, err := jaeger.Converter.ConvertArtist(ctx.Context(), source, extracted.ID)
+	if err != nil {
+		log.Printf("Error converting %s artist: %s", source.Name(), err.Error())
+		if err == errors.NotFound {
+			return util.NotFound(ctx)
 		}
-		go search.HostSpotifySearchTrackChan(spotChan)
-		spotifyTrack := <-spotChan
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, conversion)
+}
 
-		if spotifyTrack == nil {
-			continue
+// Convert is the v2 handler for converting a link (track, playlist, album or artist) to every platform. Unlike the v1.1
+// handlers, the results are keyed by platform name rather than their position in an array.
+func (jaeger *Jaeger) Convert(ctx *fiber.Ctx) error {
+	extracted := ctx.Locals("extractedInfo").(*types.ExtractedInfo)
+	result, err := jaeger.Converter.Convert(ctx.Context(), extracted)
+	if err != nil {
+		log.Printf("Error converting %s %s: %s", extracted.Host, extracted.Type, err.Error())
+		var linkErr *errors.LinkError
+		if goerrors.As(err, &linkErr) {
+			return util.BadRequest(ctx, err)
 		}
+		if goerrors.Is(err, errors.NotFound) {
+			return util.NotFound(ctx)
+		}
+		return util.InternalServerError(ctx, err)
+	}
 
-		deezerPlaylist = append(deezerPlaylist, *deezerTrack)
-		spotifPlaylist = append(spotifPlaylist, *spotifyTrack)
+	util.IncrementSearches(jaeger.Cache.Backend)
+	return util.RequestOk(ctx, result)
+}
+
+// ConvertBatch converts the links in the body ({"urls": [...]}) at once. The result of each link is keyed by the link and
+// a link that cant be converted has an error instead of failing the others.
+func (jaeger *Jaeger) ConvertBatch(ctx *fiber.Ctx) error {
+	body := struct {
+		URLs []string `json:"urls"`
+	}{}
+	err := ctx.BodyParser(&body)
+	if err != nil || len(body.URLs) == 0 {
+		log.Println("Error parsing batch body")
+		return util.BadRequest(ctx, errors.IncompleteRequest)
 	}
-	outputs = append(outputs, deezerPlaylist)
-	outputs = append(outputs, spotifPlaylist)
-	return util.RequestOk(ctx, outputs)
+	if len(body.URLs) > converter.MaxBatchSize {
+		return util.BadRequest(ctx, fmt.Errorf("a batch can have at most %d links", converter.MaxBatchSize))
+	}
+
+	result := jaeger.Converter.ConvertBatch(ctx.Context(), body.URLs, jaeger.Resolver)
+	log.Printf("Converted a batch of %d links in %dms", len(body.URLs), result.TookMs)
+	return util.RequestOk(ctx, result)
 }
 
 // now that we have the playlist for each, we want to look for the equivalent for each track
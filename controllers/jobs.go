@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	goerrors "errors"
+	"log"
+	"net/http"
+	"net/url"
+	"zoove/errors"
+	"zoove/jobs"
+	"zoove/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Jobs is the handler for conversion jobs
+type Jobs struct {
+	Queue    *jobs.Queue
+	Resolver *util.LinkResolver
+}
+
+// NewJobs returns a new jobs handler
+func NewJobs(queue *jobs.Queue, resolver *util.LinkResolver) *Jobs {
+	return &Jobs{Queue: queue, Resolver: resolver}
+}
+
+// CreateJob queues the conversion of the link in the body and returns the job. Its progress and result are polled
+// with GetJob or, when the body has a callback_url (and the X-Client-ID header is set), sent there once it is done.
+func (handler *Jobs) CreateJob(ctx *fiber.Ctx) error {
+	body := struct {
+		URL         string `json:"url"`
+		CallbackURL string `json:"callback_url"`
+	}{}
+	err := ctx.BodyParser(&body)
+	if err != nil || body.URL == "" {
+		log.Println("Error parsing job body")
+		return util.BadRequest(ctx, errors.IncompleteRequest)
+	}
+
+	// the webhook is signed with the secret of the client so only known clients can have one
+	clientID := ctx.Get("X-Client-ID")
+	if body.CallbackURL != "" {
+		callback, err := url.Parse(body.CallbackURL)
+		if err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "" {
+			return util.BadRequest(ctx, &errors.LinkError{Link: body.CallbackURL, Err: errors.InvalidLink})
+		}
+		if clientID == "" || handler.Queue.Webhooks == nil {
+			return util.RequestUnAuthorized(ctx, errors.UnAuthorized)
+		}
+		_, err = handler.Queue.Webhooks.Secrets.Secret(ctx.Context(), clientID)
+		if err != nil {
+			if goerrors.Is(err, errors.NotFound) {
+				return util.RequestUnAuthorized(ctx, errors.UnAuthorized)
+			}
+			log.Println("Error getting webhook client")
+			log.Println(err)
+			return util.InternalServerError(ctx, err)
+		}
+	}
+
+	link, err := handler.Resolver.Resolve(body.URL)
+	if err != nil {
+		return util.BadRequest(ctx, err)
+	}
+	extracted, err := util.ExtractInfoMetadata(link)
+	if err != nil {
+		return util.BadRequest(ctx, err)
+	}
+
+	job, err := handler.Queue.Enqueue(link, extracted, body.CallbackURL, clientID)
+	if err != nil {
+		log.Println("Error queueing job")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return ctx.Status(http.StatusAccepted).JSON(fiber.Map{"message": "The job has been queued", "error": nil, "status": http.StatusAccepted, "data": job})
+}
+
+// GetJob returns the status, progress and (once it is done) result of a job
+func (handler *Jobs) GetJob(ctx *fiber.Ctx) error {
+	job, err := handler.Queue.Get(ctx.Params("id"))
+	if err != nil {
+		if goerrors.Is(err, errors.NotFound) {
+			return util.NotFound(ctx)
+		}
+		log.Println("Error getting job")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, job)
+}
+
+// CancelJob cancels a job. A running job's status changes to cancelled once its worker stops it.
+func (handler *Jobs) CancelJob(ctx *fiber.Ctx) error {
+	job, err := handler.Queue.Cancel(ctx.Params("id"))
+	if err != nil {
+		if goerrors.Is(err, errors.NotFound) {
+			return util.NotFound(ctx)
+		}
+		log.Println("Error cancelling job")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, job)
+}
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"log"
+	"strconv"
+	"zoove/errors"
+	"zoove/mappings"
+	"zoove/types"
+	"zoove/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// DefaultReportsLimit is the number of reports in a page when the query doesnt say
+	DefaultReportsLimit = 50
+	// MaxReportsLimit is the max number of reports in a page
+	MaxReportsLimit = 200
+)
+
+// Reports is the handler for wrong match reports and the overrides admins pin for them
+type Reports struct {
+	Store    *mappings.PrismaStore
+	Resolver *util.LinkResolver
+}
+
+// NewReports returns a new reports handler
+func NewReports(store *mappings.PrismaStore, resolver *util.LinkResolver) *Reports {
+	return &Reports{Store: store, Resolver: resolver}
+}
+
+// CreateReport reports that the track in url was converted to the wrong track (match_url) on another platform.
+// corrected_url is the track it should have been. Either can be left out but not both.
+func (handler *Reports) CreateReport(ctx *fiber.Ctx) error {
+	body := struct {
+		URL          string `json:"url"`
+		MatchURL     string `json:"match_url"`
+		CorrectedURL string `json:"corrected_url"`
+	}{}
+	err := ctx.BodyParser(&body)
+	if err != nil || body.URL == "" || (body.MatchURL == "" && body.CorrectedURL == "") {
+		log.Println("Error parsing report body")
+		return util.BadRequest(ctx, errors.IncompleteRequest)
+	}
+
+	source, err := handler.track(body.URL)
+	if err != nil {
+		return util.BadRequest(ctx, err)
+	}
+	report := &types.MatchReport{UserUUID: ctx.Locals("uuid").(string), SourcePlatform: source.Host, SourceID: source.ID}
+	if body.MatchURL != "" {
+		match, err := handler.track(body.MatchURL)
+		if err != nil {
+			return util.BadRequest(ctx, err)
+		}
+		report.TargetPlatform, report.TargetID = match.Host, match.ID
+	}
+	if body.CorrectedURL != "" {
+		corrected, err := handler.track(body.CorrectedURL)
+		if err != nil {
+			return util.BadRequest(ctx, err)
+		}
+		if report.TargetPlatform != "" && corrected.Host != report.TargetPlatform {
+			return util.BadRequest(ctx, errors.MismatchedPlatforms)
+		}
+		report.TargetPlatform, report.CorrectedID = corrected.Host, corrected.ID
+	}
+	if report.TargetPlatform == report.SourcePlatform {
+		return util.BadRequest(ctx, errors.MismatchedPlatforms)
+	}
+
+	saved, err := handler.Store.Report(context.Background(), report)
+	if err != nil {
+		log.Println("Error saving match report")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestCreated(ctx, saved)
+}
+
+// GetReports returns a page of the reports with the status in the query (open by default, "all" for all of them). The
+// page (from 1) and the number of reports in it (limit, at most MaxReportsLimit) are in the query too.
+func (handler *Reports) GetReports(ctx *fiber.Ctx) error {
+	status := ctx.Query("status", mappings.ReportOpen)
+	if status == "all" {
+		status = ""
+	}
+	page, err := strconv.Atoi(ctx.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(ctx.Query("limit", strconv.Itoa(DefaultReportsLimit)))
+	if err != nil || limit < 1 || limit > MaxReportsLimit {
+		limit = DefaultReportsLimit
+	}
+	reports, err := handler.Store.Reports(context.Background(), status, limit, (page-1)*limit)
+	if err != nil {
+		log.Println("Error getting match reports")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, reports)
+}
+
+// PinReport pins an override for the reported match. It is the track in corrected_url or, when the body has none, the
+// corrected track of the report.
+func (handler *Reports) PinReport(ctx *fiber.Ctx) error {
+	body := struct {
+		CorrectedURL string `json:"corrected_url"`
+	}{}
+	// the body is optional
+	_ = ctx.BodyParser(&body)
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return util.NotFound(ctx)
+	}
+	report, err := handler.Store.GetReport(context.Background(), id)
+	if err != nil {
+		if goerrors.Is(err, errors.NotFound) {
+			return util.NotFound(ctx)
+		}
+		log.Println("Error getting match report")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+
+	override := &types.MappingOverride{SourcePlatform: report.SourcePlatform, SourceID: report.SourceID,
+		TargetPlatform: report.TargetPlatform, TargetID: report.CorrectedID}
+	if body.CorrectedURL != "" {
+		corrected, err := handler.track(body.CorrectedURL)
+		if err != nil {
+			return util.BadRequest(ctx, err)
+		}
+		if corrected.Host != report.TargetPlatform {
+			return util.BadRequest(ctx, errors.MismatchedPlatforms)
+		}
+		override.TargetID = corrected.ID
+	}
+	if override.TargetID == "" {
+		return util.BadRequest(ctx, errors.IncompleteRequest)
+	}
+	return handler.pin(ctx, override)
+}
+
+// DismissReport marks a report as reviewed without pinning an override
+func (handler *Reports) DismissReport(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return util.NotFound(ctx)
+	}
+	report, err := handler.Store.Dismiss(context.Background(), id, ctx.Locals("uuid").(string))
+	if err != nil {
+		if goerrors.Is(err, errors.NotFound) {
+			return util.NotFound(ctx)
+		}
+		log.Println("Error dismissing match report")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, report)
+}
+
+// CreateOverride pins the track in corrected_url as the track in url on the other platform, without a report
+func (handler *Reports) CreateOverride(ctx *fiber.Ctx) error {
+	body := struct {
+		URL          string `json:"url"`
+		CorrectedURL string `json:"corrected_url"`
+	}{}
+	err := ctx.BodyParser(&body)
+	if err != nil || body.URL == "" || body.CorrectedURL == "" {
+		log.Println("Error parsing override body")
+		return util.BadRequest(ctx, errors.IncompleteRequest)
+	}
+	source, err := handler.track(body.URL)
+	if err != nil {
+		return util.BadRequest(ctx, err)
+	}
+	corrected, err := handler.track(body.CorrectedURL)
+	if err != nil {
+		return util.BadRequest(ctx, err)
+	}
+	if corrected.Host == source.Host {
+		return util.BadRequest(ctx, errors.MismatchedPlatforms)
+	}
+	return handler.pin(ctx, &types.MappingOverride{SourcePlatform: source.Host, SourceID: source.ID,
+		TargetPlatform: corrected.Host, TargetID: corrected.ID})
+}
+
+// GetOverrides returns the pinned overrides
+func (handler *Reports) GetOverrides(ctx *fiber.Ctx) error {
+	overrides, err := handler.Store.Overrides(context.Background())
+	if err != nil {
+		log.Println("Error getting overrides")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, overrides)
+}
+
+// DeleteOverride unpins an override. The converter goes back to the tracks it saved (or searches)
+func (handler *Reports) DeleteOverride(ctx *fiber.Ctx) error {
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return util.NotFound(ctx)
+	}
+	override, err := handler.Store.Unpin(context.Background(), id)
+	if err != nil {
+		if goerrors.Is(err, errors.NotFound) {
+			return util.NotFound(ctx)
+		}
+		log.Println("Error deleting override")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, override)
+}
+
+// pin pins the override as the admin making the request
+func (handler *Reports) pin(ctx *fiber.Ctx, override *types.MappingOverride) error {
+	pinned, err := handler.Store.Pin(context.Background(), override, ctx.Locals("uuid").(string))
+	if err != nil {
+		log.Println("Error pinning override")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, pinned)
+}
+
+// track resolves a link and returns its extracted info. It returns a *errors.LinkError when it isnt a track link
+func (handler *Reports) track(link string) (*types.ExtractedInfo, error) {
+	resolved, err := handler.Resolver.Resolve(link)
+	if err != nil {
+		return nil, err
+	}
+	extracted, err := util.ExtractInfoMetadata(resolved)
+	if err != nil {
+		return nil, err
+	}
+	if extracted.Type != "track" {
+		return nil, &errors.LinkError{Link: link, Err: errors.UnsupportedLinkType}
+	}
+	return extracted, nil
+}
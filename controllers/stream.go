@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"zoove/progress"
+	"zoove/types"
+	"zoove/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// StreamPollInterval is how often a stream checks for new events
+	StreamPollInterval = 250 * time.Millisecond
+	// StreamKeepAlive is how often a comment is sent on a stream with no new events so that proxies dont close it
+	StreamKeepAlive = 15 * time.Second
+	// StreamIdleTimeout is how long a stream waits for a new event before it gives up
+	StreamIdleTimeout = 2 * time.Minute
+	// StreamConversionTimeout is how long a conversion run for a stream can take
+	StreamConversionTimeout = 5 * time.Minute
+)
+
+// ConvertStream streams the progress of a conversion as server-sent events: "meta" first, then "track" for each track
+// of a playlist and then "done" (or "error"). The conversion is run once for everyone streaming the same link and its
+// events are kept in the cache backend, so a client that reconnects with Last-Event-ID gets the events it missed.
+func (jaeger *Jaeger) ConvertStream(ctx *fiber.Ctx) error {
+	extracted := ctx.Locals("extractedInfo").(*types.ExtractedInfo)
+	key := progress.Key(extracted)
+	lastID, err := strconv.Atoi(ctx.Get("Last-Event-ID", "0"))
+	if err != nil || lastID < 0 {
+		lastID = 0
+	}
+
+	_, finished, err := jaeger.Progress.Since(key, 0)
+	if err != nil {
+		log.Println("Error getting conversion progress")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	if !finished {
+		locked, err := jaeger.Progress.Lock(key)
+		if err != nil {
+			log.Println("Error locking conversion progress")
+			log.Println(err)
+			return util.InternalServerError(ctx, err)
+		}
+		// when nobody is running the conversion, we run it. if there are events, they are from a conversion that
+		// didnt finish so they are started over
+		if locked {
+			if err := jaeger.Progress.Reset(key); err != nil {
+				jaeger.Progress.Unlock(key)
+				return util.InternalServerError(ctx, err)
+			}
+			lastID = 0
+			go jaeger.produce(key, extracted)
+		}
+	}
+
+	ctx.Set("Content-Type", "text/event-stream")
+	ctx.Set("Cache-Control", "no-cache")
+	ctx.Set("Connection", "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		jaeger.stream(w, key, lastID)
+	})
+	return nil
+}
+
+// stream writes the events of the conversion with the key (after lastID) until the conversion finishes or the client leaves
+func (jaeger *Jaeger) stream(w *bufio.Writer, key string, lastID int) {
+	lastEvent := time.Now()
+	lastWrite := time.Now()
+	for {
+		events, finished, err := jaeger.Progress.Since(key, lastID)
+		if err != nil {
+			log.Println("Error getting conversion progress")
+			log.Println(err)
+			writeStreamError(w, util.ErrorCodeInternal, "could not get the progress of the conversion")
+			return
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Name, event.Data)
+			lastID = event.ID
+		}
+		if len(events) > 0 {
+			lastEvent, lastWrite = time.Now(), time.Now()
+		} else if time.Since(lastWrite) > StreamKeepAlive {
+			fmt.Fprint(w, ": keep-alive\n\n")
+			lastWrite = time.Now()
+		}
+
+		// flushing fails once the client has left
+		if err := w.Flush(); err != nil || finished {
+			return
+		}
+		if time.Since(lastEvent) > StreamIdleTimeout {
+			writeStreamError(w, util.ErrorCodeInternal, "the conversion stopped making progress")
+			return
+		}
+		time.Sleep(StreamPollInterval)
+	}
+}
+
+// produce runs the conversion of the extracted link and adds its events to the progress log
+func (jaeger *Jaeger) produce(key string, extracted *types.ExtractedInfo) {
+	defer jaeger.Progress.Unlock(key)
+	ctx, cancel := context.WithTimeout(context.Background(), StreamConversionTimeout)
+	defer cancel()
+
+	appendEvent := func(name string, data interface{}) {
+		if err := jaeger.Progress.Append(key, name, data); err != nil {
+			log.Printf("Error adding %s event to conversion progress\n", name)
+			log.Println(err)
+		}
+	}
+
+	meta := &types.StreamMeta{Version: util.APIVersion, Type: extracted.Type, Source: extracted.Host}
+	// playlists send their meta once the playlist has been fetched
+	onPlaylist := func(playlist *types.Playlist) {
+		playlistMeta := *playlist
+		playlistMeta.Tracks = nil
+		meta.Playlist = &playlistMeta
+		appendEvent("meta", meta)
+	}
+	onTrack := func(index int, conversion *types.Conversion) {
+		appendEvent("track", &types.StreamTrack{Index: index, Track: conversion})
+	}
+	if extracted.Type != "playlist" {
+		appendEvent("meta", meta)
+	}
+
+	result, err := jaeger.Converter.StreamConvert(ctx, extracted, onPlaylist, onTrack)
+	if err != nil {
+		log.Printf("Error converting %s %s for stream: %s", extracted.Host, extracted.Type, err.Error())
+		appendEvent("error", &types.SocketError{Code: util.ErrorCode(err), Message: err.Error()})
+		return
+	}
+	// the tracks of a playlist have already been sent
+	if result.Playlist != nil {
+		result.Playlist.Tracks = nil
+	}
+	appendEvent("done", result)
+}
+
+// writeStreamError writes an error event that isnt in the progress log (so it has no ID)
+func writeStreamError(w *bufio.Writer, code, message string) {
+	fmt.Fprintf(w, "event: error\ndata: {\"code\":%q,\"message\":%q}\n\n", code, message)
+	w.Flush()
+}
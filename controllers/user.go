@@ -9,36 +9,28 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
 	"strings"
 	"time"
+	"zoove/cache"
 	"zoove/db"
 	"zoove/platforms"
 	"zoove/types"
 	"zoove/util"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gomodule/redigo/redis"
 	"github.com/google/uuid"
-	"github.com/soveran/redisurl"
 	"github.com/zmb3/spotify"
 )
 
 // User represents blueprint of things needed to perform operations for user
 type User struct {
 	DB    *db.PrismaClient
-	Redis *redis.Pool
+	Cache *cache.Cache
 }
 
 // NewUserHandler returns a new pointer for user we want to perform operations on
-func NewUserHandler(db *db.PrismaClient, pool *redis.Pool) *User {
-	pool = &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			log.Println(os.Getenv("REDIS_URL"))
-			return redisurl.Connect()
-		},
-	}
-	return &User{DB: db, Redis: pool}
+func NewUserHandler(db *db.PrismaClient, store *cache.Cache) *User {
+	return &User{DB: db, Cache: store}
 }
 
 // VerifyDeezerSignup verifies the access token a user copied is still valid
@@ -79,184 +71,92 @@ func (user *User) VerifyDeezerSignup(ctx *fiber.Ctx) error {
 func (user *User) AuthorizeUser(ctx *fiber.Ctx) error {
 	rnid, _ := uuid.NewRandom()
 	randomid := rnid.String()
-	platform := strings.ToLower(ctx.Params("platform"))
+	platform, ok := platforms.Get(strings.ToLower(ctx.Params("platform")))
+	if !ok {
+		return util.NotImplementedError(ctx, nil)
+	}
 	authcode := ctx.Query("code")
 
-	// log.Println("Platform is: and the code is: ", platform, authcode)
-	if platform == util.HostDeezer {
-		token, err := platforms.HostDeezerUserAuth(authcode)
-		if err != nil {
-			// log.Println("Error authenticating using on deezer")
-			log.Println(err)
-			return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Error Authing user", "error": err.Error(), "status": http.StatusInternalServerError})
-		}
-
-		profile, err := platforms.HostDeezerFetchUserProfile(token)
-		if err != nil {
-			log.Println("Error fetching user profile")
-			return ctx.Status(http.StatusInternalServerError).JSON(err)
-		}
-
-		ctx.Locals("token", token)
-		platformid := strconv.FormatInt(int64(profile.ID), 10)
-
-		claims := &types.Token{
-			Platform:      util.HostDeezer,
-			PlatformID:    platformid,
-			PlatformToken: "", // token,
-			UUID:          randomid,
-		}
+	profile, err := platform.UserAuth(authcode)
+	if err != nil {
+		log.Printf("Error authorizing %s user\n", platform.Name())
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
 
-		existing, err := user.DB.User.FindOne(db.User.Email.Equals(profile.Email)).Exec(context.Background())
-		if err != nil {
-			if err == db.ErrNotFound {
-				signedJWT, err := util.SignJwtTokenExp(claims, os.Getenv("JWT_SECRET"))
-
-				if err != nil {
-					panic(err)
-				}
-				plan := ""
-				if profile.Status == 1 {
-					plan = "free"
-				} else if profile.Status == 2 {
-					plan = "premium"
-				}
-
-				uid := strconv.Itoa(profile.ID)
-				log.Println("User does not exist. should create now")
-				_, err = user.DB.User.CreateOne(
-					db.User.UpdatedAt.Set(time.Now()),
-					db.User.FullName.Set(fmt.Sprintf("%s %s", profile.Firstname, profile.Lastname)),
-					db.User.FirstName.Set(profile.Firstname),
-					db.User.LastName.Set(profile.Lastname),
-					db.User.Country.Set(profile.Country),
-					db.User.Lang.Set(profile.Lang),
-					db.User.UUID.Set(randomid),
-					db.User.Email.Set(profile.Email),
-					db.User.Username.Set(profile.Name),
-					db.User.Platform.Set(util.HostDeezer),
-					db.User.Avatar.Set(profile.Picture),
-					db.User.Token.Set(token), // T0DO: ENCRYPT THIS..
-					db.User.Plan.Set(plan),
-					db.User.PlatformID.Set(uid),
-				).Exec(context.Background())
-				if err != nil {
-					log.Println("Error saving new user")
-					log.Println(err)
-					return util.BadRequest(ctx, err)
-				}
-
-				clientURL := os.Getenv("CLIENT_URL")
-				_ = map[string]string{
-					"token": signedJWT,
-				}
-
-				encToken := base64.StdEncoding.EncodeToString([]byte(signedJWT))
-				redirectURL := fmt.Sprintf("%s?kyn=%s", clientURL, encToken)
-				return ctx.Redirect(redirectURL, http.StatusTemporaryRedirect)
-				// return ctx.Status(http.StatusOK).JSON(redirectURL)
-			}
-		}
+	claims := &types.Token{
+		Platform:      platform.Name(),
+		PlatformID:    profile.PlatformID,
+		PlatformToken: "",
+		UUID:          randomid,
+	}
 
-		// update here with new token
-		// log.Printf("New token for the user from deezer auth is: %s\n", token)
-		_, err = user.DB.User.FindOne(db.User.ID.Equals(existing.ID)).Update(db.User.Token.Set(token)).Exec(context.Background())
-		if err != nil {
-			log.Println("Error updating user token")
+	existing, err := user.DB.User.FindOne(db.User.Email.Equals(profile.Email)).Exec(context.Background())
+	if err != nil {
+		if err != db.ErrNotFound {
+			log.Println("Error finding from the record")
+			log.Println(err)
 			return util.InternalServerError(ctx, err)
 		}
-		clientURL := os.Getenv("CLIENT_URL")
-		claims.UUID = existing.UUID
-		signedJwt, err := util.SignJwtToken(claims, os.Getenv("JWT_SECRET"))
-		_ = map[string]string{
-			"token": signedJwt,
-		}
 
-		encToken := base64.StdEncoding.EncodeToString([]byte(signedJwt))
-		redirectURL := fmt.Sprintf("%s?kyn=%s", clientURL, encToken)
-		return ctx.Redirect(redirectURL, http.StatusTemporaryRedirect)
-		// return ctx.Status(http.StatusOK).JSON(redirectURL)
-	} else if platform == util.HostSpotify {
-		spotify, refreshToken, err := platforms.HostSpotifyUserAuth(authcode)
-		log.Println("Error with spotify auth: ", err)
-		log.Println("Here is the spotify user auth: ", spotify, refreshToken)
+		// new deezer users get a short lived token which they verify (VerifyDeezerSignup) to get the real one.
+		sign := util.SignJwtToken
+		if platform.Name() == util.HostDeezer {
+			sign = util.SignJwtTokenExp
+		}
+		signedJwt, err := sign(claims, os.Getenv("JWT_SECRET"))
 		if err != nil {
-			log.Println("Error getting user")
 			log.Println(err)
-			// panic(err)
 			return util.InternalServerError(ctx, err)
 		}
-		claims := &types.Token{
-			Platform:      util.HostSpotify,
-			PlatformID:    spotify.ID,
-			PlatformToken: "",
-			UUID:          randomid,
-		}
-
-		existing, err := user.DB.User.FindOne(db.User.Email.Equals(spotify.Email)).Exec(context.Background())
 
+		log.Println("User does not exist. create new")
+		_, err = user.DB.User.CreateOne(
+			db.User.UpdatedAt.Set(time.Now()),
+			db.User.FullName.Set(strings.TrimSpace(fmt.Sprintf("%s %s", profile.FirstName, profile.LastName))),
+			db.User.FirstName.Set(profile.FirstName),
+			db.User.LastName.Set(profile.LastName),
+			db.User.Country.Set(profile.Country),
+			db.User.Lang.Set(profile.Lang),
+			db.User.UUID.Set(randomid),
+			db.User.Email.Set(profile.Email),
+			db.User.Username.Set(profile.Username),
+			db.User.Platform.Set(platform.Name()),
+			db.User.Avatar.Set(profile.Avatar),
+			db.User.Token.Set(profile.Token), // T0DO: ENCRYPT THIS..
+			db.User.Plan.Set(profile.Plan),
+			db.User.PlatformID.Set(profile.PlatformID),
+		).Exec(context.Background())
 		if err != nil {
-			log.Println("Error finding from the record")
-			if err == db.ErrNotFound {
-				signedJwt, err := util.SignJwtToken(claims, os.Getenv("JWT_SECRET"))
-				ppix := ""
-				if len(spotify.Images) == 0 {
-					ppix = ""
-				} else {
-					ppix = spotify.Images[0].URL
-				}
-				log.Println("User does not exist. create new")
-				_, err = user.DB.User.CreateOne(
-					db.User.UpdatedAt.Set(time.Now()),
-					db.User.FullName.Set(""),
-					db.User.FirstName.Set(""),
-					db.User.LastName.Set(""),
-					db.User.Country.Set(spotify.Country),
-					db.User.Lang.Set("en"),
-					db.User.UUID.Set(randomid),
-					db.User.Email.Set(spotify.Email),
-					db.User.Username.Set(spotify.DisplayName),
-					db.User.Platform.Set(util.HostSpotify),
-					db.User.Avatar.Set(ppix),
-					db.User.Token.Set(refreshToken),
-					db.User.Plan.Set(spotify.Product),
-					db.User.PlatformID.Set(spotify.ID),
-				).Exec(context.Background())
-
-				if err != nil {
-					log.Println("Error creating new user")
-					log.Println(err)
-					return util.InternalServerError(ctx, err)
-				}
-				clientURL := os.Getenv("CLIENT_URL")
-
-				encToken := base64.StdEncoding.EncodeToString([]byte(signedJwt))
-				redirectURL := fmt.Sprintf("%s?kyn=%s", clientURL, encToken)
-				return ctx.Redirect(redirectURL, http.StatusTemporaryRedirect)
-				// util.RequestOk(ctx, encToken)
-				// return ctx.Status(http.StatusOK).JSON(redirectURL)
-			}
-		}
-		// update here with new token
-		_, err = user.DB.User.FindOne(db.User.ID.Equals(existing.ID)).Update(db.User.Token.Set(refreshToken)).Exec(context.Background())
-		if err != nil {
-			log.Println("Error updating user token")
+			log.Println("Error creating new user")
+			log.Println(err)
 			return util.InternalServerError(ctx, err)
 		}
+		return redirectToClient(ctx, signedJwt)
+	}
 
-		clientURL := os.Getenv("CLIENT_URL")
-		claims.UUID = existing.UUID
-		signedJwt, err := util.SignJwtToken(claims, os.Getenv("JWT_SECRET"))
-		_ = map[string]string{
-			"token": signedJwt,
-		}
+	// update here with new token
+	_, err = user.DB.User.FindOne(db.User.ID.Equals(existing.ID)).Update(db.User.Token.Set(profile.Token)).Exec(context.Background())
+	if err != nil {
+		log.Println("Error updating user token")
+		return util.InternalServerError(ctx, err)
+	}
 
-		encToken := base64.StdEncoding.EncodeToString([]byte(signedJwt))
-		redirectURL := fmt.Sprintf("%s?kyn=%s", clientURL, encToken)
-		return ctx.Redirect(redirectURL, http.StatusTemporaryRedirect)
-		// return ctx.Status(http.StatusOK).JSON(redirectURL)
+	claims.UUID = existing.UUID
+	signedJwt, err := util.SignJwtToken(claims, os.Getenv("JWT_SECRET"))
+	if err != nil {
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
 	}
-	return util.NotImplementedError(ctx, nil)
+	return redirectToClient(ctx, signedJwt)
+}
+
+// redirectToClient redirects the user to the client with the (encoded) signed jwt
+func redirectToClient(ctx *fiber.Ctx, signedJwt string) error {
+	clientURL := os.Getenv("CLIENT_URL")
+	encToken := base64.StdEncoding.EncodeToString([]byte(signedJwt))
+	redirectURL := fmt.Sprintf("%s?kyn=%s", clientURL, encToken)
+	return ctx.Redirect(redirectURL, http.StatusTemporaryRedirect)
 }
 
 // GetUserProfile updates a user profile
@@ -303,8 +203,6 @@ func (user *User) UpdateUserProfile(ctx *fiber.Ctx) error {
 
 // GetListeningHistory returns the listening history for a user
 func (user *User) GetListeningHistory(ctx *fiber.Ctx) error {
-	conn := user.Redis.Get()
-	defer conn.Close()
 	history := []types.SingleTrack{}
 	uuid := ctx.Locals("uuid").(string)
 
@@ -314,23 +212,17 @@ func (user *User) GetListeningHistory(ctx *fiber.Ctx) error {
 		return util.InternalServerError(ctx, err)
 	}
 
-	if existing.Platform == util.HostDeezer {
+	platform, ok := platforms.Get(existing.Platform)
+	if ok {
 		if existing.Token == "" {
 			// TODO: reauth user
 		}
-		history, err = platforms.HostDeezerFetchHistory(existing.Token)
+		history, err = platform.FetchHistory(existing.Token)
 		if err != nil {
-			log.Println("Error fetching user deezer history")
+			log.Printf("Error fetching user %s history\n", platform.Name())
 			log.Println(err)
 			return util.InternalServerError(ctx, err)
 		}
-
-	} else if existing.Platform == util.HostSpotify {
-		history, err = platforms.HostSpotifyListeningHistory(existing.Token)
-		if err != nil {
-			log.Printf("Error getting spotify listening history: %#v\n", err)
-			return util.InternalServerError(ctx, err)
-		}
 	}
 
 	key := fmt.Sprintf("user-%s", existing.UUID)
@@ -340,25 +232,16 @@ func (user *User) GetListeningHistory(ctx *fiber.Ctx) error {
 		log.Printf("%#v\n", err)
 	}
 
-	_, err = redis.String(conn.Do("GET", key))
+	err = user.Cache.Backend.Set(key, serialize, 0)
 	if err != nil {
-		log.Printf("Error getting from redis..:%s", err)
-	}
-
-	_, err = redis.Int64(conn.Do("DEL", key))
-	if err != nil {
-		log.Println("Error removing from redis.")
+		log.Println("Error caching user history")
 		log.Println(err)
 	}
-
-	_, err = redis.String(conn.Do("SET", key, string(serialize)))
 	return util.RequestOk(ctx, history)
 }
 
 // GetArtistePlayHistory returns the playlist history of the artistes a user has played
 func (user *User) GetArtistePlayHistory(ctx *fiber.Ctx) error {
-	conn := user.Redis.Get()
-	defer conn.Close()
 	uuid := ctx.Locals("uuid").(string)
 	existing, _ := user.DB.User.FindOne(db.User.UUID.Equals(uuid)).Exec(context.Background())
 
@@ -369,13 +252,13 @@ func (user *User) GetArtistePlayHistory(ctx *fiber.Ctx) error {
 	}
 	key := fmt.Sprintf("user-%s", existing.UUID)
 	hist := &[]types.SingleTrack{}
-	cached, err := redis.String(conn.Do("GET", key))
+	cached, err := user.Cache.Backend.Get(key)
 	if err != nil {
-		if err == redis.ErrNil {
+		if err == cache.ErrMiss {
 			return util.NotFound(ctx)
 		}
 	}
-	err = json.Unmarshal([]byte(cached), hist)
+	err = json.Unmarshal(cached, hist)
 	if err != nil {
 		return util.BadRequest(ctx, err)
 	}
@@ -493,22 +376,16 @@ func (user *User) CreatePlaylist(ctx *fiber.Ctx) error {
 		log.Println(err)
 		return util.InternalServerError(ctx, err)
 	}
-	if platform == util.HostDeezer {
-		err = platforms.HostDeezerCreatePlaylist(newPlaylist.Title, existing.UUID, existing.Token, newPlaylist.Payload)
-		if err != nil {
-			log.Println("Error creating playlists for deezer user")
-			log.Println(err)
-			return util.InternalServerError(ctx, err)
-		}
-	} else if platform == util.HostSpotify {
-		err := platforms.HostSpotifyCreatePlaylist(existing.UUID, newPlaylist.Title, existing.Token, newPlaylist.Payload)
-		if err != nil {
-			log.Println("Error creating playlist for user for spotify")
-			log.Println(err)
-			return util.InternalServerError(ctx, err)
-		}
-		return util.RequestOk(ctx, nil)
+	target, ok := platforms.Get(platform)
+	if !ok {
+		return util.NotImplementedError(ctx, nil)
 	}
 
-	return nil
+	err = target.CreatePlaylist(existing.PlatformID, newPlaylist.Title, existing.Token, newPlaylist.Payload)
+	if err != nil {
+		log.Printf("Error creating playlist for %s user\n", target.Name())
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	return util.RequestOk(ctx, nil)
 }
@@ -0,0 +1,76 @@
+package converter
+
+import (
+	"context"
+	"log"
+	"sync"
+	"zoove/platforms"
+	"zoove/types"
+)
+
+// ConvertAlbum returns the album (with the id) on the source platform, the same album on every other platform and each
+// of its tracks on the other platforms' albums. The other platforms are searched at the same time, within their limits.
+func (converter *Converter) ConvertAlbum(ctx context.Context, source platforms.Platform, id string) (*types.AlbumConversion, error) {
+	release, err := converter.acquire(ctx, source.Name())
+	if err != nil {
+		return nil, err
+	}
+	album, err := source.GetAlbum(id, converter.Cache)
+	release()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := platforms.Others(source.Name())
+	found := make([]*types.Album, len(targets))
+	search := platforms.NewAlbumToSearchFromAlbum(album, converter.Cache)
+	var wg sync.WaitGroup
+	for target, platform := range targets {
+		wg.Add(1)
+		go func(target int, platform platforms.Platform) {
+			defer wg.Done()
+			release, err := converter.acquire(ctx, platform.Name())
+			if err != nil {
+				return
+			}
+			defer release()
+			found[target], err = platform.SearchAlbum(search)
+			if err != nil {
+				log.Printf("Error searching %s for album\n", platform.Name())
+				log.Println(err)
+				found[target] = nil
+			}
+		}(target, platform)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conversion := &types.AlbumConversion{Source: source.Name(), Albums: map[string]*types.Album{},
+		Tracks: []map[string]*types.SingleTrack{}}
+	for index := range album.Tracks {
+		track := &album.Tracks[index]
+		row := map[string]*types.SingleTrack{source.Name(): track}
+		for target, platform := range targets {
+			row[platform.Name()] = platforms.MatchAlbumTrack(track, found[target])
+		}
+		conversion.Tracks = append(conversion.Tracks, row)
+	}
+
+	// the tracks are already in conversion.Tracks
+	albums := map[string]*types.Album{source.Name(): album}
+	for target, platform := range targets {
+		albums[platform.Name()] = found[target]
+	}
+	for name, found := range albums {
+		if found == nil {
+			conversion.Albums[name] = nil
+			continue
+		}
+		withoutTracks := *found
+		withoutTracks.Tracks = nil
+		conversion.Albums[name] = &withoutTracks
+	}
+	return conversion, nil
+}
@@ -0,0 +1,96 @@
+package converter
+
+import (
+	"context"
+	"log"
+	"sync"
+	"zoove/platforms"
+	"zoove/types"
+)
+
+// ConvertArtist returns the artiste (with the id) on the source platform, the same artiste on every other platform and
+// each of their top tracks on the other platforms. Like ConvertAlbum, the other platforms are searched at the same
+// time, within their limits.
+func (converter *Converter) ConvertArtist(ctx context.Context, source platforms.Platform, id string) (*types.ArtistConversion, error) {
+	release, err := converter.acquire(ctx, source.Name())
+	if err != nil {
+		return nil, err
+	}
+	artist, err := source.GetArtist(id, converter.Cache)
+	release()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := platforms.Others(source.Name())
+	found := make([]*types.Artist, len(targets))
+	search := platforms.NewArtistToSearchFromArtist(artist, converter.Cache)
+	var wg sync.WaitGroup
+	for target, platform := range targets {
+		wg.Add(1)
+		go func(target int, platform platforms.Platform) {
+			defer wg.Done()
+			release, err := converter.acquire(ctx, platform.Name())
+			if err != nil {
+				return
+			}
+			defer release()
+			found[target], err = platform.SearchArtist(search)
+			if err != nil {
+				log.Printf("Error searching %s for artiste\n", platform.Name())
+				log.Println(err)
+				found[target] = nil
+			}
+		}(target, platform)
+	}
+	wg.Wait()
+
+	// the top tracks are only searched for on the platforms the artiste was found on
+	topTracks := make([][]*types.SingleTrack, len(artist.TopTracks))
+	for index := range artist.TopTracks {
+		topTracks[index] = make([]*types.SingleTrack, len(targets))
+		trackSearch := platforms.NewTrackToSearchFromTrack(&artist.TopTracks[index], converter.Cache)
+		for target, platform := range targets {
+			if found[target] == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(index, target int, platform platforms.Platform) {
+				defer wg.Done()
+				track, err := converter.search(ctx, platform, trackSearch)
+				if err != nil {
+					log.Printf("Error searching %s for top track\n", platform.Name())
+					log.Println(err)
+					return
+				}
+				topTracks[index][target] = track
+			}(index, target, platform)
+		}
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conversion := &types.ArtistConversion{Source: source.Name(), Artists: map[string]*types.Artist{},
+		TopTracks: []map[string]*types.SingleTrack{}}
+	for index := range artist.TopTracks {
+		row := map[string]*types.SingleTrack{source.Name(): &artist.TopTracks[index]}
+		for target, platform := range targets {
+			row[platform.Name()] = topTracks[index][target]
+		}
+		conversion.TopTracks = append(conversion.TopTracks, row)
+	}
+
+	// the top tracks are already in conversion.TopTracks
+	withoutTracks := *artist
+	withoutTracks.TopTracks = nil
+	conversion.Artists[source.Name()] = &withoutTracks
+	for target, platform := range targets {
+		if found[target] != nil {
+			found[target].TopTracks = nil
+		}
+		conversion.Artists[platform.Name()] = found[target]
+	}
+	return conversion, nil
+}
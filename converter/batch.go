@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"zoove/types"
+	"zoove/util"
+)
+
+// MaxBatchSize is the max number of links converted in one batch
+const MaxBatchSize = 50
+
+// ConvertBatch converts many links (tracks, playlists, albums or artists) at once. Links that are the same (or resolve
+// to the same thing) are only converted once. A link that cant be converted has an error instead of failing the batch.
+func (converter *Converter) ConvertBatch(ctx context.Context, links []string, resolver *util.LinkResolver) *types.BatchResult {
+	start := time.Now()
+	unique := map[string]bool{}
+	for _, link := range links {
+		unique[strings.TrimSpace(link)] = true
+	}
+
+	// the links are resolved first so that different links for the same thing are only converted once
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	items := map[string]*types.BatchItem{}
+	extracted := map[string]*types.ExtractedInfo{}
+	for link := range unique {
+		wg.Add(1)
+		go func(link string) {
+			defer wg.Done()
+			info, err := extract(link, resolver)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				items[link] = &types.BatchItem{Error: &types.SocketError{Code: util.ErrorCode(err), Message: err.Error()}}
+				return
+			}
+			extracted[link] = info
+		}(link)
+	}
+	wg.Wait()
+
+	conversions := map[string]*types.BatchItem{}
+	for _, info := range extracted {
+		key := batchKey(info)
+		if _, ok := conversions[key]; ok {
+			continue
+		}
+		item := &types.BatchItem{}
+		conversions[key] = item
+		wg.Add(1)
+		go func(info *types.ExtractedInfo, item *types.BatchItem) {
+			defer wg.Done()
+			result, err := converter.Convert(ctx, info)
+			if err != nil {
+				item.Error = &types.SocketError{Code: util.ErrorCode(err), Message: err.Error()}
+				return
+			}
+			item.Result = result
+		}(info, item)
+	}
+	wg.Wait()
+
+	for link, info := range extracted {
+		items[link] = conversions[batchKey(info)]
+	}
+	results := map[string]*types.BatchItem{}
+	for _, link := range links {
+		results[link] = items[strings.TrimSpace(link)]
+	}
+	return &types.BatchResult{Version: util.APIVersion, Results: results, TookMs: time.Since(start).Milliseconds()}
+}
+
+// extract resolves a link (if it is a share link) and returns its extracted info
+func extract(link string, resolver *util.LinkResolver) (*types.ExtractedInfo, error) {
+	resolved, err := resolver.Resolve(link)
+	if err != nil {
+		return nil, err
+	}
+	return util.ExtractInfoMetadata(resolved)
+}
+
+// batchKey returns the key that is the same for links of the same thing
+func batchKey(info *types.ExtractedInfo) string {
+	return fmt.Sprintf("%s-%s-%s", info.Host, info.Type, info.ID)
+}
@@ -0,0 +1,305 @@
+// Package converter converts playlists (and their tracks) from one platform to the others, searching the platforms concurrently.
+package converter
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zoove/cache"
+	"zoove/errors"
+	"zoove/platforms"
+	"zoove/types"
+	"zoove/util"
+)
+
+// DefaultConcurrency is the max number of searches running at the same time on a platform when it isnt set in the env
+const DefaultConcurrency = 8
+
+// Converter converts playlists from one platform to the others. Requests to each platform are limited to Limits[platform]
+// at a time, across all the conversions the Converter is running.
+type Converter struct {
+	Cache  *cache.Cache
+	Limits map[string]int
+	// Store (optional) keeps the tracks that have been matched so that they dont have to be searched for again
+	Store Store
+
+	mutex      sync.Mutex
+	semaphores map[string]chan struct{}
+}
+
+// NewConverter returns a new Converter. The limit of each platform is read from <PLATFORM>_CONCURRENCY (e.g DEEZER_CONCURRENCY)
+func NewConverter(store *cache.Cache) *Converter {
+	limits := map[string]int{}
+	for _, platform := range platforms.All() {
+		limits[platform.Name()] = DefaultConcurrency
+		value := os.Getenv(fmt.Sprintf("%s_CONCURRENCY", strings.ToUpper(platform.Name())))
+		if value == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			log.Printf("Invalid concurrency %q for %s. Using %d\n", value, platform.Name(), DefaultConcurrency)
+			continue
+		}
+		limits[platform.Name()] = limit
+	}
+	return &Converter{Cache: store, Limits: limits, semaphores: map[string]chan struct{}{}}
+}
+
+// ConvertPlaylist returns the playlist (with the id) on the source platform and each of its tracks on the other platforms.
+func (converter *Converter) ConvertPlaylist(ctx context.Context, source platforms.Platform, id string) (*types.PlaylistConversion, error) {
+	return converter.StreamPlaylist(ctx, source, id, nil, nil)
+}
+
+// StreamPlaylist is ConvertPlaylist but onPlaylist is called with the playlist once it has been fetched and onTrack with
+// each track as soon as it has been converted (so not in the order of the playlist). Either can be nil. They are never
+// called at the same time.
+//
+// When the playlist has been converted before, its last conversion is returned as it was if the playlist hasnt changed
+// since (its snapshot is the same). Otherwise only the tracks added since are converted. Either way, the tracks whose
+// last conversion cant be reused (see reusable) are converted again.
+func (converter *Converter) StreamPlaylist(ctx context.Context, source platforms.Platform, id string, onPlaylist func(*types.Playlist), onTrack func(int, *types.TrackConversion)) (*types.PlaylistConversion, error) {
+	start := time.Now()
+	cacheID := platforms.PlaylistCacheID(source.Name(), id)
+	previous := converter.previous(cacheID)
+	snapshot := ""
+	if previous != nil {
+		snapshot = converter.snapshot(ctx, source, id)
+		if snapshot != "" && snapshot == previous.Playlist.Snapshot && converter.unchanged(previous) {
+			return replay(previous, start, onPlaylist, onTrack), nil
+		}
+	}
+
+	release, err := converter.acquire(ctx, source.Name())
+	if err != nil {
+		return nil, err
+	}
+	playlist, err := source.FetchPlaylistTracks(id, converter.Cache)
+	if err == nil && snapshot != "" && playlist.Snapshot != snapshot {
+		// the cached playlist is older than the playlist
+		if err := converter.Cache.Delete(cache.Playlist, cacheID); err != nil {
+			log.Printf("Error removing cached playlist %s\n", cacheID)
+			log.Println(err)
+		}
+		playlist, err = source.FetchPlaylistTracks(id, converter.Cache)
+	}
+	release()
+	if err != nil {
+		return nil, err
+	}
+	if onPlaylist != nil {
+		onPlaylist(&playlist)
+	}
+
+	conversion := &types.PlaylistConversion{Source: source.Name(), Playlist: playlist}
+	conversion.Tracks, conversion.Changes = converter.convertChanges(ctx, source, &playlist, previous, onTrack)
+	conversion.Summary = Summarize(conversion.Tracks)
+	conversion.TookMs = time.Since(start).Milliseconds()
+	// a conversion that was cancelled is missing tracks
+	if ctx.Err() == nil {
+		converter.remember(cacheID, conversion)
+	}
+	return conversion, nil
+}
+
+// ConvertTracks searches for the tracks (from the source platform) on the other platforms. The conversions are returned
+// in the same order as the tracks (one for each track), with a null track and an error for each platform a track wasnt found on.
+func (converter *Converter) ConvertTracks(ctx context.Context, source platforms.Platform, tracks []types.SingleTrack) []types.TrackConversion {
+	return converter.StreamTracks(ctx, source, tracks, nil)
+}
+
+// StreamTracks is ConvertTracks but onTrack (if it isnt nil) is called with each track (and its index) as soon as it has
+// been converted. It is never called twice at the same time.
+func (converter *Converter) StreamTracks(ctx context.Context, source platforms.Platform, tracks []types.SingleTrack, onTrack func(int, *types.TrackConversion)) []types.TrackConversion {
+	targets := platforms.Others(source.Name())
+	// each track writes to its own slot so there's no need to lock. only onTrack is
+	conversions := make([]types.TrackConversion, len(tracks))
+	var onTrackMutex sync.Mutex
+
+	// the tracks are converted by a few workers, not all at once, since each conversion also looks up the store and
+	// the cache. there's no point having more than the platforms can search at the same time
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < converter.workers(targets, len(tracks)); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				conversions[index] = converter.convertTrack(ctx, source, targets, &tracks[index])
+				if onTrack != nil {
+					onTrackMutex.Lock()
+					onTrack(index, &conversions[index])
+					onTrackMutex.Unlock()
+				}
+			}
+		}()
+	}
+	for index := range tracks {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return conversions
+}
+
+// workers returns the number of tracks converted at the same time: the highest limit of the target platforms, and no
+// more than there are tracks
+func (converter *Converter) workers(targets []platforms.Platform, tracks int) int {
+	workers := 1
+	for _, platform := range targets {
+		limit := converter.Limits[platform.Name()]
+		if limit < 1 {
+			limit = DefaultConcurrency
+		}
+		if limit > workers {
+			workers = limit
+		}
+	}
+	if workers > tracks {
+		workers = tracks
+	}
+	return workers
+}
+
+// convertTrack searches for a track on the target platforms at the same time
+func (converter *Converter) convertTrack(ctx context.Context, source platforms.Platform, targets []platforms.Platform, track *types.SingleTrack) types.TrackConversion {
+	search := platforms.NewTrackToSearchFromTrack(track, converter.Cache)
+	mappings := converter.lookup(ctx, track)
+	found := make([]*types.SingleTrack, len(targets))
+	failed := make([]error, len(targets))
+	searched := make([]bool, len(targets))
+	var wg sync.WaitGroup
+	for target, platform := range targets {
+		wg.Add(1)
+		go func(target int, platform platforms.Platform) {
+			defer wg.Done()
+			if mapping, ok := mappings[platform.Name()]; ok {
+				mapped, err := converter.fetch(ctx, platform, mapping)
+				if err == nil && mapped != nil {
+					found[target] = mapped
+					return
+				}
+				// the track might have been removed from the platform. it is searched for instead
+				log.Printf("Error fetching mapped %s track %s. Searching instead\n", platform.Name(), mapping.PlatformID)
+			}
+			found[target], failed[target] = converter.Cache.Conversion(track, platform.Name(), func() (*types.SingleTrack, error) {
+				searched[target] = true
+				return converter.search(ctx, platform, search)
+			})
+		}(target, platform)
+	}
+	wg.Wait()
+
+	conversion := types.TrackConversion{Tracks: map[string]*types.SingleTrack{source.Name(): track}, ConvertedAt: time.Now()}
+	row := []*types.SingleTrack{track}
+	for target, platform := range targets {
+		conversion.Tracks[platform.Name()] = found[target]
+		if err := failed[target]; err != nil {
+			if conversion.Errors == nil {
+				conversion.Errors = map[string]*types.TrackError{}
+			}
+			conversion.Errors[platform.Name()] = &types.TrackError{Reason: Reason(err), Message: err.Error()}
+			continue
+		}
+		row = append(row, found[target])
+	}
+	util.ShareReleaseDate(row...)
+
+	// only new matches need saving
+	for target := range targets {
+		if searched[target] && found[target] != nil {
+			converter.save(ctx, &conversion)
+			break
+		}
+	}
+	return conversion
+}
+
+// Summarize returns the number of tracks found (and missing) on each platform
+func Summarize(conversions []types.TrackConversion) *types.PlaylistSummary {
+	summary := &types.PlaylistSummary{Total: len(conversions), Found: map[string]int{}, Missing: map[string]int{}}
+	for _, platform := range platforms.All() {
+		summary.Found[platform.Name()] = 0
+		summary.Missing[platform.Name()] = 0
+	}
+	for index := range conversions {
+		complete := true
+		for _, platform := range platforms.All() {
+			if conversions[index].Tracks[platform.Name()] == nil {
+				summary.Missing[platform.Name()]++
+				complete = false
+				continue
+			}
+			summary.Found[platform.Name()]++
+		}
+		if complete {
+			summary.Complete++
+		}
+	}
+	return summary
+}
+
+// Reason returns the reason (not_found, upstream_error or region_blocked) a search failed with the error
+func Reason(err error) string {
+	if goerrors.Is(err, errors.NotFound) {
+		return util.ReasonNotFound
+	}
+	if goerrors.Is(err, errors.RegionBlocked) {
+		return util.ReasonRegionBlocked
+	}
+	return util.ReasonUpstreamError
+}
+
+// search searches the platform for a track once there's room under the platform's limit
+func (converter *Converter) search(ctx context.Context, platform platforms.Platform, search *platforms.TrackToSearch) (*types.SingleTrack, error) {
+	release, err := converter.acquire(ctx, platform.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	track, err := platform.SearchTrack(search)
+	if err == nil && track == nil {
+		return nil, errors.NotFound
+	}
+	return track, err
+}
+
+// acquire waits until there's room under the platform's limit. release must be called once the request to the
+// platform is done.
+func (converter *Converter) acquire(ctx context.Context, platform string) (release func(), err error) {
+	semaphore := converter.semaphore(platform)
+	select {
+	case semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	// it might have been cancelled while waiting
+	if err := ctx.Err(); err != nil {
+		<-semaphore
+		return nil, err
+	}
+	return func() { <-semaphore }, nil
+}
+
+// semaphore returns the channel limiting the number of searches running at the same time on a platform
+func (converter *Converter) semaphore(platform string) chan struct{} {
+	converter.mutex.Lock()
+	defer converter.mutex.Unlock()
+	semaphore, ok := converter.semaphores[platform]
+	if !ok {
+		limit := converter.Limits[platform]
+		if limit < 1 {
+			limit = DefaultConcurrency
+		}
+		semaphore = make(chan struct{}, limit)
+		converter.semaphores[platform] = semaphore
+	}
+	return semaphore
+}
@@ -0,0 +1,131 @@
+package converter
+
+import (
+	"context"
+	"zoove/errors"
+	"zoove/platforms"
+	"zoove/types"
+	"zoove/util"
+)
+
+// Convert converts the track, playlist, album or artist in the extracted link to every platform.
+func (converter *Converter) Convert(ctx context.Context, extracted *types.ExtractedInfo) (*types.ConversionResult, error) {
+	return converter.StreamConvert(ctx, extracted, nil, nil)
+}
+
+// StreamConvert is Convert but, for playlists, onPlaylist and onTrack are called as the playlist is converted (see
+// StreamPlaylist). Either can be nil.
+func (converter *Converter) StreamConvert(ctx context.Context, extracted *types.ExtractedInfo, onPlaylist func(*types.Playlist), onTrack func(int, *types.Conversion)) (*types.ConversionResult, error) {
+	source, ok := platforms.Get(extracted.Host)
+	if !ok {
+		return nil, &errors.LinkError{Link: extracted.URL, Err: errors.UnsupportedPlatform}
+	}
+
+	result := &types.ConversionResult{Version: util.APIVersion, Type: extracted.Type}
+	switch extracted.Type {
+	case "track":
+		conversion, err := converter.ConvertTrack(ctx, source, extracted.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Track = conversion
+	case "playlist":
+		var onTrackConversion func(int, *types.TrackConversion)
+		if onTrack != nil {
+			onTrackConversion = func(index int, conversion *types.TrackConversion) {
+				onTrack(index, NewConversion(source.Name(), *conversion))
+			}
+		}
+		conversion, err := converter.StreamPlaylist(ctx, source, extracted.ID, onPlaylist, onTrackConversion)
+		if err != nil {
+			return nil, err
+		}
+		result.Playlist = NewPlaylistResult(conversion)
+	case "album":
+		conversion, err := converter.ConvertAlbum(ctx, source, extracted.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Album = conversion
+	case "artist":
+		conversion, err := converter.ConvertArtist(ctx, source, extracted.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.Artist = conversion
+	default:
+		return nil, &errors.LinkError{Link: extracted.URL, Err: errors.UnsupportedLinkType}
+	}
+	return result, nil
+}
+
+// ConvertTrack returns the track (with the id) on the source platform and on the other platforms.
+func (converter *Converter) ConvertTrack(ctx context.Context, source platforms.Platform, id string) (*types.Conversion, error) {
+	release, err := converter.acquire(ctx, source.Name())
+	if err != nil {
+		return nil, err
+	}
+	track, err := source.GetSingleTrack(id, converter.Cache)
+	release()
+	if err != nil {
+		return nil, err
+	}
+	if track == nil {
+		return nil, errors.NotFound
+	}
+	conversions := converter.ConvertTracks(ctx, source, []types.SingleTrack{*track})
+	return NewConversion(source.Name(), conversions[0]), nil
+}
+
+// NewConversion returns the v2 conversion of a track conversion. The match metadata of each track is moved to its Match.
+func NewConversion(source string, conversion types.TrackConversion) *types.Conversion {
+	result := &types.Conversion{Platforms: map[string]*types.PlatformResult{}}
+	for _, platform := range platforms.All() {
+		platformResult := &types.PlatformResult{Error: conversion.Errors[platform.Name()]}
+		if found := conversion.Tracks[platform.Name()]; found != nil {
+			track := *found
+			match := &types.Match{Strategy: track.MatchStrategy, Confidence: track.Confidence, Alternatives: track.Alternatives}
+			if platform.Name() == source {
+				match = &types.Match{Strategy: util.MatchStrategySource, Confidence: 1}
+				result.Source = &track
+			}
+			track.MatchStrategy, track.Confidence, track.Alternatives = "", 0, nil
+			platformResult.Track, platformResult.Match = &track, match
+		} else if platformResult.Error == nil {
+			platformResult.Error = &types.TrackError{Reason: util.ReasonNotFound, Message: errors.NotFound.Error()}
+		}
+		result.Platforms[platform.Name()] = platformResult
+	}
+	return result
+}
+
+// NewPlaylistResult returns the v2 result of a playlist conversion
+func NewPlaylistResult(conversion *types.PlaylistConversion) *types.PlaylistResult {
+	result := &types.PlaylistResult{Source: conversion.Source, Playlist: conversion.Playlist,
+		Tracks: make([]types.Conversion, len(conversion.Tracks)), Summary: conversion.Summary, Changes: conversion.Changes,
+		TookMs: conversion.TookMs}
+	result.Playlist.Tracks = nil
+	for index := range conversion.Tracks {
+		result.Tracks[index] = *NewConversion(conversion.Source, conversion.Tracks[index])
+	}
+	return result
+}
+
+// Tracks returns the track on each platform of a conversion in the order of platforms.All(), the way v1.1 returns them:
+// with the match on the track and an empty track for the platforms it wasnt found on.
+func Tracks(conversion *types.Conversion) []types.SingleTrack {
+	tracks := []types.SingleTrack{}
+	for _, platform := range platforms.All() {
+		result := conversion.Platforms[platform.Name()]
+		if result == nil || result.Track == nil {
+			tracks = append(tracks, types.SingleTrack{})
+			continue
+		}
+		track := *result.Track
+		if result.Track != conversion.Source && result.Match != nil {
+			track.MatchStrategy, track.Confidence, track.Alternatives = result.Match.Strategy, result.Match.Confidence, result.Match.Alternatives
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks
+}
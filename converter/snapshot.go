@@ -0,0 +1,161 @@
+package converter
+
+import (
+	"context"
+	"log"
+	"time"
+	"zoove/cache"
+	"zoove/platforms"
+	"zoove/types"
+	"zoove/util"
+)
+
+// previous returns the last conversion of the playlist (with the cache id), nil when there's none
+func (converter *Converter) previous(id string) *types.PlaylistConversion {
+	previous := &types.PlaylistConversion{}
+	err := converter.Cache.Get(cache.PlaylistConversion, id, previous)
+	if err != nil {
+		return nil
+	}
+	return previous
+}
+
+// remember caches the conversion of the playlist (with the cache id) so that it can be reused the next time
+func (converter *Converter) remember(id string, conversion *types.PlaylistConversion) {
+	remembered := *conversion
+	remembered.Changes = nil
+	err := converter.Cache.Set(cache.PlaylistConversion, id, &remembered)
+	if err != nil {
+		log.Printf("Error caching the conversion of playlist %s\n", id)
+		log.Println(err)
+	}
+}
+
+// snapshot returns the current snapshot of the playlist on the source platform. It is empty when it couldnt be fetched,
+// then the playlist is fetched as if it had changed.
+func (converter *Converter) snapshot(ctx context.Context, source platforms.Platform, id string) string {
+	release, err := converter.acquire(ctx, source.Name())
+	if err != nil {
+		return ""
+	}
+	defer release()
+	snapshot, err := source.PlaylistSnapshot(id)
+	if err != nil {
+		log.Printf("Error fetching the snapshot of %s playlist %s\n", source.Name(), id)
+		log.Println(err)
+		return ""
+	}
+	return snapshot
+}
+
+// replay returns the last conversion of a playlist that hasnt changed, calling onPlaylist and onTrack like it had just
+// been converted.
+func replay(previous *types.PlaylistConversion, start time.Time, onPlaylist func(*types.Playlist), onTrack func(int, *types.TrackConversion)) *types.PlaylistConversion {
+	if onPlaylist != nil {
+		onPlaylist(&previous.Playlist)
+	}
+	if onTrack != nil {
+		for index := range previous.Tracks {
+			onTrack(index, &previous.Tracks[index])
+		}
+	}
+	previous.Changes = &types.PlaylistChanges{Snapshot: previous.Playlist.Snapshot, Added: []types.SingleTrack{},
+		Removed: []types.SingleTrack{}}
+	previous.TookMs = time.Since(start).Milliseconds()
+	return previous
+}
+
+// convertChanges converts the tracks of a playlist, reusing the conversions of the tracks that were already in it when
+// it was last converted (previous, which can be nil). It returns the conversions (in the order of the tracks) and what
+// changed since the last conversion (nil when there's none).
+func (converter *Converter) convertChanges(ctx context.Context, source platforms.Platform, playlist *types.Playlist, previous *types.PlaylistConversion, onTrack func(int, *types.TrackConversion)) ([]types.TrackConversion, *types.PlaylistChanges) {
+	if previous == nil {
+		return converter.StreamTracks(ctx, source, playlist.Tracks, onTrack), nil
+	}
+
+	reusable := converter.reusable()
+	converted := map[string]*types.TrackConversion{}
+	for index := range previous.Tracks {
+		track := previous.Tracks[index].Tracks[source.Name()]
+		if track != nil && track.ID != "" && reusable(&previous.Tracks[index]) {
+			converted[track.ID] = &previous.Tracks[index]
+		}
+	}
+	wasInPlaylist := map[string]bool{}
+	for _, track := range previous.Playlist.Tracks {
+		wasInPlaylist[track.ID] = true
+	}
+
+	changes := &types.PlaylistChanges{Snapshot: previous.Playlist.Snapshot, Added: []types.SingleTrack{},
+		Removed: []types.SingleTrack{}}
+	conversions := make([]types.TrackConversion, len(playlist.Tracks))
+	isInPlaylist := map[string]bool{}
+	// the tracks that are converted, and their index in the playlist
+	tracks, indexes := []types.SingleTrack{}, []int{}
+	for index, track := range playlist.Tracks {
+		isInPlaylist[track.ID] = true
+		if reused, ok := converted[track.ID]; ok {
+			conversions[index] = *reused
+			if onTrack != nil {
+				onTrack(index, &conversions[index])
+			}
+			continue
+		}
+		// tracks that failed last time are converted again but they werent added
+		if !wasInPlaylist[track.ID] {
+			changes.Added = append(changes.Added, track)
+		}
+		tracks, indexes = append(tracks, track), append(indexes, index)
+	}
+	for _, track := range previous.Playlist.Tracks {
+		if !isInPlaylist[track.ID] {
+			changes.Removed = append(changes.Removed, track)
+			// a track in the playlist twice is only removed once
+			isInPlaylist[track.ID] = true
+		}
+	}
+	changes.Changed = playlist.Snapshot != previous.Playlist.Snapshot || len(changes.Added) > 0 || len(changes.Removed) > 0
+
+	if len(tracks) > 0 {
+		added := converter.StreamTracks(ctx, source, tracks, func(index int, conversion *types.TrackConversion) {
+			if onTrack != nil {
+				onTrack(indexes[index], conversion)
+			}
+		})
+		for index := range added {
+			conversions[indexes[index]] = added[index]
+		}
+	}
+	return conversions, changes
+}
+
+// reusable returns a func that returns true if a conversion can be reused when its track is still in the playlist.
+// Tracks that failed for another reason than not being found are converted again, and so are those that werent found
+// once not found results are no longer cached (after NegativeTTL), since they might have been added to the platform.
+// Conversions from before an override was pinned or removed are converted again too, in case the override is for one
+// of their tracks.
+func (converter *Converter) reusable() func(conversion *types.TrackConversion) bool {
+	overridesChanged := converter.Cache.OverridesChanged()
+	return func(conversion *types.TrackConversion) bool {
+		if !conversion.ConvertedAt.After(overridesChanged) {
+			return false
+		}
+		for _, err := range conversion.Errors {
+			if err.Reason != util.ReasonNotFound || time.Since(conversion.ConvertedAt) >= converter.Cache.NegativeTTL {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// unchanged returns true if the conversion of every track of the playlist conversion can be reused (see reusable)
+func (converter *Converter) unchanged(conversion *types.PlaylistConversion) bool {
+	reusable := converter.reusable()
+	for index := range conversion.Tracks {
+		if !reusable(&conversion.Tracks[index]) {
+			return false
+		}
+	}
+	return true
+}
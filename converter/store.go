@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"context"
+	"log"
+	"zoove/platforms"
+	"zoove/types"
+	"zoove/util"
+)
+
+// Store keeps the tracks that have been matched across platforms so that a track converted once (from either platform)
+// is fetched by its ID instead of being searched for again.
+type Store interface {
+	// Lookup returns the tracks on the other platforms that are the same as the track, keyed by platform. Platforms the
+	// track hasnt been matched on are missing.
+	Lookup(ctx context.Context, track *types.SingleTrack) (map[string]*types.TrackMapping, error)
+	// Save saves the tracks of a conversion as the same track
+	Save(ctx context.Context, conversion *types.TrackConversion) error
+}
+
+// lookup returns the mappings of a track from the store (if there is one). Errors are only logged since the track can
+// still be searched for.
+func (converter *Converter) lookup(ctx context.Context, track *types.SingleTrack) map[string]*types.TrackMapping {
+	if converter.Store == nil {
+		return nil
+	}
+	mappings, err := converter.Store.Lookup(ctx, track)
+	if err != nil {
+		log.Printf("Error looking up the mappings of %s track %s\n", track.Platform, track.ID)
+		log.Println(err)
+		return nil
+	}
+	return mappings
+}
+
+// fetch returns the track of a mapping from its platform
+func (converter *Converter) fetch(ctx context.Context, platform platforms.Platform, mapping *types.TrackMapping) (*types.SingleTrack, error) {
+	release, err := converter.acquire(ctx, platform.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	track, err := platform.GetSingleTrack(mapping.PlatformID, converter.Cache)
+	if err != nil {
+		return nil, err
+	}
+	if track == nil {
+		return nil, nil
+	}
+	// the track is cached and shared, so it isnt changed
+	matched := *track
+	matched.MatchStrategy = util.MatchStrategyMapping
+	if mapping.Source == util.MatchStrategyOverride {
+		matched.MatchStrategy = util.MatchStrategyOverride
+	}
+	matched.Confidence = mapping.Confidence
+	return &matched, nil
+}
+
+// save saves a conversion to the store (if there is one)
+func (converter *Converter) save(ctx context.Context, conversion *types.TrackConversion) {
+	if converter.Store == nil {
+		return
+	}
+	err := converter.Store.Save(ctx, conversion)
+	if err != nil {
+		log.Println("Error saving track mappings")
+		log.Println(err)
+	}
+}
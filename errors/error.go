@@ -1,8 +1,34 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var UnAuthorized = errors.New("Error authorizing this guy.")
+var Forbidden = errors.New("Only admins can do this")
 var NotFound = errors.New("Not Found")
 var IncompleteRequest = errors.New("The request is incomplete. An import part is missing")
 var BadOrInvalidJwt = errors.New("malformed authorization token")
+var RegionBlocked = errors.New("Not available in this region")
+var InvalidLink = errors.New("Link is not a valid link")
+var UnsupportedPlatform = errors.New("Link is not from a supported platform")
+var UnsupportedLinkType = errors.New("Link is not a track, album, artist or playlist")
+var UnresolvableLink = errors.New("Short link could not be resolved")
+var MismatchedPlatforms = errors.New("The links are not from the right platforms")
+var Interrupted = errors.New("The job was interrupted before it finished. Try again")
+
+// LinkError is returned when a link cannot be used. Err is InvalidLink, UnsupportedPlatform, UnsupportedLinkType or UnresolvableLink
+type LinkError struct {
+	Link string
+	Err  error
+}
+
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err.Error(), e.Link)
+}
+
+// Unwrap returns the link error so that errors.Is(err, InvalidLink) (etc) works
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
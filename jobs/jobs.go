@@ -0,0 +1,448 @@
+// Package jobs runs conversions in the background. Jobs are queued in the cache backend (see cache.Backend) and picked
+// up by workers so that big playlists dont have to be converted within a request. The state of each job is kept there
+// for clients to poll.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"zoove/cache"
+	"zoove/converter"
+	"zoove/errors"
+	"zoove/types"
+	"zoove/util"
+	"zoove/webhooks"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// QueueKey is the key of the queue of the IDs of the queued jobs
+	QueueKey = "jobs"
+	// ProcessingKey is the prefix of the key of each worker's list of the IDs of the jobs it has taken off the queue
+	// and hasnt finished yet
+	ProcessingKey = "jobs-processing"
+	// WorkersKey is the key of the list of the IDs of the workers (of every instance) that have taken jobs off the queue
+	WorkersKey = "jobs-workers"
+	// DefaultTTL is how long a job is kept after it was last updated
+	DefaultTTL = 24 * time.Hour
+	// DefaultWorkers is the number of workers running jobs when JOB_WORKERS isnt set
+	DefaultWorkers = 2
+	// pollTimeout is how long a worker waits for a job before checking if it should stop
+	pollTimeout = 5 * time.Second
+	// cancelInterval is how often a running job checks if it has been cancelled
+	cancelInterval = time.Second
+	// heartbeatTTL is how long a worker is considered working after it last said so. Workers say so before taking a
+	// job off the queue and every cancelInterval while they run it.
+	heartbeatTTL = 30 * time.Second
+	// recoverInterval is how often the jobs left behind by workers that stopped are recovered (see Recover)
+	recoverInterval = heartbeatTTL
+	// recoverLockKey is set while an instance is recovering the jobs so that only one of them does
+	recoverLockKey = "jobs-recover-lock"
+)
+
+// The status of a job
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Queue queues and runs conversion jobs. Webhooks (optional) sends the jobs with a callback URL once they are done or
+// have failed.
+type Queue struct {
+	Backend   cache.Backend
+	Converter *converter.Converter
+	Webhooks  *webhooks.Deliverer
+	TTL       time.Duration
+}
+
+// NewQueue returns a new Queue that runs the jobs with the converter
+func NewQueue(backend cache.Backend, converter *converter.Converter) *Queue {
+	return &Queue{Backend: backend, Converter: converter, TTL: DefaultTTL}
+}
+
+// Workers returns the number of workers to run, read from JOB_WORKERS
+func Workers() int {
+	workers, err := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+	if err != nil || workers < 1 {
+		return DefaultWorkers
+	}
+	return workers
+}
+
+// Enqueue queues a job converting the link. The link should already have been resolved (see util.LinkResolver).
+// callbackURL and clientID are empty when the client doesnt want a webhook.
+func (queue *Queue) Enqueue(link string, extracted *types.ExtractedInfo, callbackURL, clientID string) (*types.Job, error) {
+	now := time.Now()
+	job := &types.Job{ID: uuid.New().String(), Status: StatusQueued, URL: link, Type: extracted.Type,
+		CreatedAt: now, UpdatedAt: now, CallbackURL: callbackURL, ClientID: clientID}
+	err := queue.save(job)
+	if err != nil {
+		return nil, err
+	}
+
+	err = queue.Backend.Push(QueueKey, []byte(job.ID))
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Get returns the job with the ID. It returns errors.NotFound if there is no such job (or it has expired)
+func (queue *Queue) Get(id string) (*types.Job, error) {
+	value, err := queue.Backend.Get(jobKey(id))
+	if err == cache.ErrMiss {
+		return nil, errors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := &types.Job{}
+	err = json.Unmarshal(value, job)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Cancel cancels the job with the ID. A queued job is cancelled right away while a running job is cancelled by its
+// worker (within a second). Jobs that have finished are returned as they are.
+func (queue *Queue) Cancel(id string) (*types.Job, error) {
+	job, err := queue.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != StatusQueued && job.Status != StatusRunning {
+		return job, nil
+	}
+
+	err = queue.Backend.Set(cancelKey(id), []byte("1"), queue.TTL)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == StatusQueued {
+		queue.cancel(job)
+		if err := queue.save(job); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// Work runs the queued jobs with the number of workers until the context is done. The jobs left behind by workers that
+// stopped (e.g the server was restarted or a worker crashed) are recovered first and then every recoverInterval (see
+// Recover).
+func (queue *Queue) Work(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		queue.keepRecovering(ctx)
+	}()
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queue.work(ctx, uuid.New().String())
+		}()
+	}
+	wg.Wait()
+}
+
+// keepRecovering recovers the jobs left behind right away and then every recoverInterval until the context is done
+func (queue *Queue) keepRecovering(ctx context.Context) {
+	ticker := time.NewTicker(recoverInterval)
+	defer ticker.Stop()
+	for {
+		if err := queue.Recover(); err != nil {
+			log.Println("Error recovering the jobs left in processing")
+			log.Println(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recover goes through the jobs taken off the queue by workers that have stopped (they havent sent a heartbeat for
+// heartbeatTTL). The ones that hadnt started are queued again. The ones that were running are failed since they might
+// be what stopped the worker, and the others have already finished. Workers send their heartbeat before they take a job
+// off the queue, so the jobs of the workers still working (on any instance) are left alone, even the ones just taken.
+// Only one instance recovers the jobs at a time.
+func (queue *Queue) Recover() error {
+	locked, err := queue.Backend.SetNX(recoverLockKey, []byte("1"), recoverInterval)
+	if err != nil || !locked {
+		return err
+	}
+	defer queue.Backend.Delete(recoverLockKey)
+
+	workers, err := queue.Backend.Range(WorkersKey, 0, -1)
+	if err != nil {
+		return err
+	}
+	for _, worker := range workers {
+		if _, err := queue.Backend.Get(heartbeatKey(string(worker))); err == nil {
+			continue
+		}
+		if err := queue.recoverWorker(string(worker)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recoverWorker recovers the jobs taken off the queue by the worker (which has stopped) and forgets the worker
+func (queue *Queue) recoverWorker(worker string) error {
+	ids, err := queue.Backend.Range(processingKey(worker), 0, -1)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		job, err := queue.Get(string(id))
+		switch {
+		case err == errors.NotFound:
+			// it has expired
+		case err != nil:
+			return err
+		case job.Status == StatusQueued:
+			log.Printf("Queueing job %s again\n", job.ID)
+			if err := queue.Backend.Push(QueueKey, id); err != nil {
+				return err
+			}
+		case job.Status == StatusRunning:
+			queue.fail(job, errors.Interrupted)
+		}
+		if err := queue.Backend.Remove(processingKey(worker), id); err != nil {
+			return err
+		}
+	}
+	return queue.Backend.Remove(WorkersKey, []byte(worker))
+}
+
+// work runs the queued jobs one at a time with the worker (ID) until the context is done
+func (queue *Queue) work(ctx context.Context, worker string) {
+	defer queue.leave(worker)
+	for ctx.Err() == nil {
+		// before taking a job so that Recover never takes it for a job left behind
+		if err := queue.heartbeat(worker); err != nil {
+			log.Println("Error saving the heartbeat of a worker")
+			log.Println(err)
+			time.Sleep(time.Second)
+			continue
+		}
+		id, err := queue.Backend.Pop(QueueKey, processingKey(worker), pollTimeout)
+		if err == cache.ErrMiss {
+			continue
+		}
+		if err != nil {
+			log.Println("Error getting a queued job")
+			log.Println(err)
+			// dont hammer the backend when it is down
+			time.Sleep(time.Second)
+			continue
+		}
+		queue.take(ctx, worker, string(id))
+		queue.done(worker, string(id))
+	}
+}
+
+// take runs the job with the ID with the worker if it is still queued
+func (queue *Queue) take(ctx context.Context, worker, id string) {
+	job, err := queue.Get(id)
+	if err != nil {
+		log.Printf("Error getting job %s\n", id)
+		log.Println(err)
+		return
+	}
+	if job.Status != StatusQueued {
+		return
+	}
+	queue.run(ctx, worker, job)
+}
+
+// heartbeat tells Recover that the worker is working. A worker whose heartbeat has expired (or that is new) is added
+// to the workers again since Recover might have forgotten it.
+func (queue *Queue) heartbeat(worker string) error {
+	set, err := queue.Backend.SetNX(heartbeatKey(worker), []byte("1"), heartbeatTTL)
+	if err != nil {
+		return err
+	}
+	if !set {
+		return queue.Backend.Set(heartbeatKey(worker), []byte("1"), heartbeatTTL)
+	}
+	return queue.Backend.Append(WorkersKey, []byte(worker), 0)
+}
+
+// done removes the job with the ID from the jobs the worker is working on
+func (queue *Queue) done(worker, id string) {
+	if err := queue.Backend.Remove(processingKey(worker), []byte(id)); err != nil {
+		log.Printf("Error removing job %s from processing\n", id)
+		log.Println(err)
+	}
+}
+
+// leave forgets the worker once it has stopped. A worker that couldnt remove its last job from processing is left
+// for Recover.
+func (queue *Queue) leave(worker string) {
+	processing, err := queue.Backend.Range(processingKey(worker), 0, -1)
+	if err != nil || len(processing) > 0 {
+		return
+	}
+	if err := queue.Backend.Remove(WorkersKey, []byte(worker)); err != nil {
+		log.Println("Error removing a stopped worker")
+		log.Println(err)
+	}
+	queue.Backend.Delete(heartbeatKey(worker))
+}
+
+// run runs a job with the worker and saves its progress as it goes
+func (queue *Queue) run(ctx context.Context, worker string, job *types.Job) {
+	if queue.cancelled(job.ID) {
+		queue.cancel(job)
+		queue.saveOrLog(job)
+		return
+	}
+	job.Status = StatusRunning
+	job.Progress.Total = 1
+	queue.saveOrLog(job)
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(cancelInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				if err := queue.heartbeat(worker); err != nil {
+					log.Printf("Error saving the heartbeat of the worker of job %s\n", job.ID)
+					log.Println(err)
+				}
+				if queue.cancelled(job.ID) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	extracted, err := util.ExtractInfoMetadata(job.URL)
+	if err != nil {
+		queue.fail(job, err)
+		return
+	}
+	onPlaylist := func(playlist *types.Playlist) {
+		job.Progress.Total = len(playlist.Tracks)
+		queue.saveOrLog(job)
+	}
+	onTrack := func(index int, conversion *types.Conversion) {
+		job.Progress.Done++
+		queue.saveOrLog(job)
+	}
+	result, err := queue.Converter.StreamConvert(jobCtx, extracted, onPlaylist, onTrack)
+	if jobCtx.Err() != nil && queue.cancelled(job.ID) {
+		queue.cancel(job)
+		queue.saveOrLog(job)
+		return
+	}
+	if err != nil {
+		queue.fail(job, err)
+		return
+	}
+
+	job.Status = StatusDone
+	job.Progress.Done = job.Progress.Total
+	job.Result = result
+	queue.saveOrLog(job)
+	log.Printf("Job %s (%s) done\n", job.ID, job.Type)
+	queue.notify(job)
+}
+
+// notify sends the job to its callback URL (if it has one)
+func (queue *Queue) notify(job *types.Job) {
+	if job.CallbackURL == "" || queue.Webhooks == nil {
+		return
+	}
+	// the retries can take minutes so they dont hold up the worker
+	go func() {
+		err := queue.Webhooks.DeliverJob(context.Background(), job)
+		if err != nil {
+			log.Printf("Error delivering webhook of job %s\n", job.ID)
+			log.Println(err)
+		}
+	}()
+}
+
+// fail marks a job as failed with the error
+func (queue *Queue) fail(job *types.Job, err error) {
+	log.Printf("Job %s (%s) failed\n", job.ID, job.Type)
+	log.Println(err)
+	job.Status = StatusFailed
+	job.Error = &types.SocketError{Code: util.ErrorCode(err), Message: err.Error()}
+	queue.saveOrLog(job)
+	queue.notify(job)
+}
+
+// cancel marks a job as cancelled
+func (queue *Queue) cancel(job *types.Job) {
+	job.Status = StatusCancelled
+	job.Error = &types.SocketError{Code: util.ErrorCodeCancelled, Message: "the job was cancelled"}
+}
+
+// cancelled returns true if the job with the ID has been cancelled
+func (queue *Queue) cancelled(id string) bool {
+	_, err := queue.Backend.Get(cancelKey(id))
+	return err == nil
+}
+
+// save saves the state of a job
+func (queue *Queue) save(job *types.Job) error {
+	job.UpdatedAt = time.Now()
+	value, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return queue.Backend.Set(jobKey(job.ID), value, queue.TTL)
+}
+
+// saveOrLog saves the state of a job and logs the error if it couldnt be saved. It is for jobs that are running since
+// there's nobody to return the error to.
+func (queue *Queue) saveOrLog(job *types.Job) {
+	if err := queue.save(job); err != nil {
+		log.Printf("Error saving job %s\n", job.ID)
+		log.Println(err)
+	}
+}
+
+// jobKey returns the key of the state of the job with the ID
+func jobKey(id string) string {
+	return fmt.Sprintf("job-%s", id)
+}
+
+// processingKey returns the key of the list of the jobs the worker (ID) has taken off the queue
+func processingKey(worker string) string {
+	return fmt.Sprintf("%s-%s", ProcessingKey, worker)
+}
+
+// heartbeatKey returns the key set while the worker (ID) is working
+func heartbeatKey(worker string) string {
+	return fmt.Sprintf("jobs-worker-%s-heartbeat", worker)
+}
+
+// cancelKey returns the key set when the job with the ID is cancelled
+func cancelKey(id string) string {
+	return fmt.Sprintf("job-%s-cancel", id)
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+	"zoove/cache"
+	"zoove/types"
+)
+
+func TestRecover(t *testing.T) {
+	backend := cache.NewMemoryBackend(20)
+	queue := NewQueue(backend, nil)
+	for _, job := range []*types.Job{{ID: "queued", Status: StatusQueued}, {ID: "running", Status: StatusRunning},
+		{ID: "done", Status: StatusDone}, {ID: "working", Status: StatusRunning}, {ID: "taken", Status: StatusQueued}} {
+		if err := queue.save(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a worker that stopped without sending its heartbeat again
+	backend.Append(WorkersKey, []byte("stopped"), 0)
+	for _, id := range []string{"queued", "running", "done", "expired"} {
+		backend.Push(processingKey("stopped"), []byte(id))
+	}
+	// another worker is still running a job and has just taken one off the queue
+	if err := queue.heartbeat("alive"); err != nil {
+		t.Fatal(err)
+	}
+	backend.Push(processingKey("alive"), []byte("working"))
+	backend.Push(QueueKey, []byte("taken"))
+	if _, err := backend.Pop(QueueKey, processingKey("alive"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := queue.Recover(); err != nil {
+		t.Fatal(err)
+	}
+
+	queued, _ := backend.Range(QueueKey, 0, -1)
+	if len(queued) != 1 || string(queued[0]) != "queued" {
+		t.Errorf("expected only the job that hadnt started to be queued again, got %q", queued)
+	}
+	if job, _ := queue.Get("running"); job.Status != StatusFailed {
+		t.Errorf("expected the interrupted job to have failed, got %s", job.Status)
+	}
+	if job, _ := queue.Get("done"); job.Status != StatusDone {
+		t.Errorf("expected the finished job to be left as it is, got %s", job.Status)
+	}
+	if job, _ := queue.Get("working"); job.Status != StatusRunning {
+		t.Errorf("expected the job still being worked on to be left alone, got %s", job.Status)
+	}
+	if processing, _ := backend.Range(processingKey("stopped"), 0, -1); len(processing) != 0 {
+		t.Errorf("expected the jobs of the stopped worker to be recovered, got %q", processing)
+	}
+	if processing, _ := backend.Range(processingKey("alive"), 0, -1); len(processing) != 2 {
+		t.Errorf("expected the jobs of the working worker to be left alone, got %q", processing)
+	}
+	workers, _ := backend.Range(WorkersKey, 0, -1)
+	if len(workers) != 1 || string(workers[0]) != "alive" {
+		t.Errorf("expected only the working worker to be left, got %q", workers)
+	}
+}
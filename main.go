@@ -8,28 +8,33 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"zoove/cache"
 	"zoove/controllers"
+	"zoove/converter"
 	"zoove/db"
+	"zoove/jobs"
+	"zoove/mappings"
 	"zoove/middleware"
 	"zoove/platforms"
+	"zoove/socket"
 	"zoove/types"
 	"zoove/util"
+	"zoove/webhooks"
 
 	"github.com/gofiber/websocket/v2"
-	"github.com/soveran/redisurl"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	jwtware "github.com/gofiber/jwt/v2"
-	"github.com/gomodule/redigo/redis"
 	"github.com/joho/godotenv"
 )
 
-var pool *redis.Pool
+// sharedCache is the cache every handler uses. Its backend is set by CACHE_BACKEND (see cache.NewBackend)
+var sharedCache *cache.Cache
+var resolver *util.LinkResolver
+var playlistConverter *converter.Converter
 var register = make(chan *websocket.Conn)
 var jaegerChan = make(chan *SocketMessage)
-var spotifyChan = make(chan *types.SingleTrack)
-var deezerChan = make(chan *types.SingleTrack)
 var createPlaylistChan = make(chan bool)
 
 func loadEnv() {
@@ -56,6 +61,9 @@ type SocketMessage struct {
 		Platform string   `json:"platform"`
 	} `json:"payload,omitempty"`
 	UserID string `json:"userid,omitempty"`
+	// Stream makes the playlist action send the playlist and each track as soon as they are ready, instead of one
+	// message once the whole playlist has been converted.
+	Stream bool `json:"stream,omitempty"`
 }
 
 func loadListeners() {
@@ -68,210 +76,271 @@ func loadListeners() {
 
 // SocketListener represents a "blueprint" for a typical listener
 type SocketListener struct {
-	deserialize   SocketMessage
-	c             *websocket.Conn
-	trackMeta     *types.SingleTrack
-	deezerTracks  []types.SingleTrack
-	spotifyTracks []types.SingleTrack
-	tracks        [][]types.SingleTrack
-	client        *db.PrismaClient
-	playlistMeta  *types.Playlist
+	deserialize    SocketMessage
+	c              *websocket.Conn
+	trackMeta      *types.SingleTrack
+	platformTracks map[string][]*types.SingleTrack
+	tracks         [][]types.SingleTrack
+	client         *db.PrismaClient
+	playlistMeta   *types.Playlist
+}
+
+// extractInfo returns the extracted info of the URL in the message. Share links are resolved first
+func (listener *SocketListener) extractInfo() (*types.ExtractedInfo, error) {
+	link, err := resolver.Resolve(listener.deserialize.URL)
+	if err != nil {
+		return nil, err
+	}
+	return util.ExtractInfoMetadata(link)
 }
 
 // GetTrackListener listens for tracks action
 func (listener *SocketListener) GetTrackListener() {
 	// log.Println("Deserialized extracted URL (TRACK) is: ", listener.deserialize.URL)
-	extracted, err := util.ExtractInfoMetadata(listener.deserialize.URL)
+	extracted, err := listener.extractInfo()
 	if err != nil {
 		log.Println("Error extracting")
 		log.Println(err)
-		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"error", "message":"Its me not you...."`))
+		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"error", "message":"Its me not you...."}`))
 		listener.c.Close()
+		return
 	}
-	if extracted.Host == util.HostDeezer {
-		// log.Println("Wants to search deezer")
-		listener.trackMeta, err = platforms.HostDeezerGetSingleTrack(extracted.ID, pool)
-		if err != nil {
-			listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"Error getting deezer single track"}`))
-			listener.c.Close()
-		}
-
-	} else if extracted.Host == util.HostSpotify {
-		// log.Println("Wants to search spotify")
-		listener.trackMeta, err = platforms.HostSpotifyGetSingleTrack(extracted.ID, pool)
-		if err != nil {
-			listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"Error getting spotify single track"}`))
-			listener.c.Close()
-		}
-	} else {
+	source, ok := platforms.Get(extracted.Host)
+	if !ok {
 		log.Println("Oops! Not a valid host")
 		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"Invalid host"}`))
 		listener.c.Close()
 		return
 	}
-	artiste := ""
-	if len(listener.trackMeta.Artistes) > 0 {
-		artiste = listener.trackMeta.Artistes[0]
-	}
-	search := platforms.NewTrackToSearch(listener.trackMeta.Title, artiste, pool)
-	deezr, err := search.HostDeezerSearchTrack()
-	if err != nil {
-		log.Println("Error searching deezer")
-		// log.Println("Error is: ", err)
-		// TODO: try to handle whatever happens here
-		deezr = &types.SingleTrack{}
-	}
-
-	spot, err := search.HostSpotifySearchTrack()
-	if err != nil {
-		// log.Println("Errpr searching spotify")
-		// TODO: try to handle whatever happens here
-		spot = &types.SingleTrack{}
-	}
-	conn := pool.Get()
-	defer conn.Close()
 
-	_, err = redis.String(conn.Do("GET", util.RedisSearchesKey))
+	conversion, err := playlistConverter.ConvertTrack(context.Background(), source, extracted.ID)
 	if err != nil {
-		if err == redis.ErrNil {
-			_, err := redis.String(conn.Do("SET", util.RedisSearchesKey, "1"))
-			if err != nil {
-				log.Println("Error saving searches key into redis")
-			}
-		}
+		log.Printf("Error converting %s track %s\n", source.Name(), extracted.ID)
+		log.Println(err)
+		listener.c.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"desc":"Error getting %s single track"}`, source.Name())))
+		listener.c.Close()
+		return
 	}
+	listener.trackMeta = conversion.Source
 
-	searchesCount, err := redis.Int(conn.Do("INCR", util.RedisSearchesKey))
-	if err != nil {
-		log.Println("Error incrementing redis key")
+	incrementSearches()
+	// the socket has always replied with the platforms in the reverse order of the REST API (spotify first). keeping it for the clients
+	results := converter.Tracks(conversion)
+	for index := len(results) - 1; index >= 0; index-- {
+		listener.tracks = append(listener.tracks, []types.SingleTrack{results[index]})
 	}
-	log.Printf("Number of search so far: %d\n", searchesCount)
-	deezr.ReleaseDate = spot.ReleaseDate
-	listener.deezerTracks = append(listener.deezerTracks, *deezr)
-	listener.spotifyTracks = append(listener.spotifyTracks, *spot)
-	listener.tracks = append(listener.tracks, listener.spotifyTracks, listener.deezerTracks)
 	listener.c.WriteJSON(listener.tracks)
 
 	// we gotta reset those values, else, it'd just keep pushing to the arrays and returning increasing values as the user makes more requests
 	// perhaps have @Davidemi to review this for me.
 	listener.tracks = nil
-	listener.deezerTracks = nil
-	listener.spotifyTracks = nil
 	listener.c.Close()
 }
 
 // GetPlaylistListener returns the playlist listener
 func (listener *SocketListener) GetPlaylistListener() {
 	// log.Println("Deserialized extracted URL (playlist) is: ", listener.deserialize.URL)
-	extracted, err := util.ExtractInfoMetadata(listener.deserialize.URL)
+	extracted, err := listener.extractInfo()
 	if err != nil {
 		log.Println("Error extracting")
 		log.Println(err)
-		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"error", "message":"Its me not you...."`))
+		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"error", "message":"Its me not you...."}`))
 		listener.c.Close()
+		return
 	}
 
-	if extracted.Host == util.HostDeezer {
-		deezerPl, err := platforms.HostDeezerFetchPlaylistTracks(extracted.ID, pool)
-		if err != nil {
-			log.Println("Error fetching playlist tracks.")
-			log.Println(err)
-			if err.Error() == "Not Found" {
-				listener.playlistMeta = &types.Playlist{}
-			}
+	source, ok := platforms.Get(extracted.Host)
+	if !ok {
+		log.Println("Oops! Not a valid host")
+		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"Invalid host"}`))
+		listener.c.Close()
+		return
+	}
+
+	if listener.deserialize.Stream {
+		listener.streamPlaylist(source, extracted.ID)
+		return
+	}
+
+	conversion, err := playlistConverter.ConvertPlaylist(context.Background(), source, extracted.ID)
+	if err != nil {
+		log.Printf("Error fetching %s playlist tracks.\n", source.Name())
+		log.Println(err)
+		listener.writeError(err)
+		return
+	}
+	listener.playlistMeta = &conversion.Playlist
+	log.Printf("Converted %d tracks of %s playlist in %dms\n", len(conversion.Tracks), source.Name(), conversion.TookMs)
+
+	// one row for each track of the playlist. a platform's track is null when it wasnt found there (see "tracks" for why)
+	for _, platform := range platforms.All() {
+		listener.platformTracks[platform.Name()] = []*types.SingleTrack{}
+	}
+	for index := range conversion.Tracks {
+		for _, platform := range platforms.All() {
+			listener.platformTracks[platform.Name()] = append(listener.platformTracks[platform.Name()], conversion.Tracks[index].Tracks[platform.Name()])
 		}
+	}
 
-		listener.playlistMeta = &deezerPl
+	incrementSearches()
 
-		for _, singleTrack := range listener.playlistMeta.Tracks {
-			search := platforms.NewTrackToSearch(singleTrack.Title, singleTrack.Artistes[0], pool)
-			go search.HostSpotifySearchTrackChan(spotifyChan)
-			spotifyTrack := <-spotifyChan
+	payload := [][]*types.SingleTrack{}
+	for _, platform := range platforms.All() {
+		payload = append(payload, listener.platformTracks[platform.Name()])
+	}
+	// log.Println("All tracks now are: ", listener.tracks)
+	// log.Println("Plalyist meta is: ", listener.playlistMeta)
+	res := map[string]interface{}{
+		"playlist_title": listener.playlistMeta.Title,
+		"payload":        payload,
+		"owner":          listener.playlistMeta.Owner,
+		"playlist_meta":  listener.playlistMeta,
+		"platforms":      listener.platformTracks,
+		"tracks":         conversion.Tracks,
+		"took_ms":        conversion.TookMs,
+	}
 
-			if spotifyTrack == nil {
-				continue
-			}
+	listener.c.WriteJSON(res)
+	listener.platformTracks = nil
+	listener.c.Close()
+}
 
-			listener.spotifyTracks = append(listener.spotifyTracks, *spotifyTrack)
-		}
+// streamPlaylist converts a playlist and sends the playlist first, then each track as soon as it has been converted
+// (with its index in the playlist) and then the number of tracks found on each platform.
+func (listener *SocketListener) streamPlaylist(source platforms.Platform, id string) {
+	onPlaylist := func(playlist *types.Playlist) {
+		meta := *playlist
+		meta.Tracks = nil
+		listener.c.WriteJSON(map[string]interface{}{"action": "playlist_meta", "payload": meta})
+	}
+	onTrack := func(index int, conversion *types.TrackConversion) {
+		listener.c.WriteJSON(map[string]interface{}{"action": "playlist_track", "index": index, "payload": conversion})
+	}
 
-		listener.deezerTracks = append(listener.deezerTracks, listener.playlistMeta.Tracks...)
+	conversion, err := playlistConverter.StreamPlaylist(context.Background(), source, id, onPlaylist, onTrack)
+	if err != nil {
+		log.Printf("Error fetching %s playlist tracks.\n", source.Name())
+		log.Println(err)
+		listener.writeError(err)
+		return
+	}
+	log.Printf("Converted %d tracks of %s playlist in %dms\n", len(conversion.Tracks), source.Name(), conversion.TookMs)
+	incrementSearches()
 
-	} else if extracted.Host == util.HostSpotify {
-		spotifyPl, err := platforms.HostSpotifyFetchPlaylistTracks(extracted.ID, pool)
-		if err != nil {
-			log.Println("Error fetching spotify playlist tracks.")
-		}
-		listener.playlistMeta = &spotifyPl
+	listener.c.WriteJSON(map[string]interface{}{"action": "playlist_summary", "payload": conversion.Summary, "took_ms": conversion.TookMs})
+	listener.c.Close()
+}
 
-		for _, singleTrack := range listener.playlistMeta.Tracks {
-			artiste := ""
-			if len(singleTrack.Artistes) > 0 {
-				artiste = singleTrack.Artistes[0]
-			}
+// writeError sends the error (with the same code and message as the error frames of the v2 socket) and closes the
+// connection
+func (listener *SocketListener) writeError(err error) {
+	listener.c.WriteJSON(map[string]interface{}{"desc": "error", "error": &types.SocketError{Code: util.ErrorCode(err), Message: err.Error()}})
+	listener.c.Close()
+}
 
-			search := platforms.NewTrackToSearch(singleTrack.Title, artiste, pool)
-			go search.HostDeezerSearchTrackChan(deezerChan)
-			deezerTrack := <-deezerChan
-			if deezerTrack == nil {
-				continue
-			}
-			listener.deezerTracks = append(listener.deezerTracks, *deezerTrack)
-		}
-		listener.spotifyTracks = append(listener.spotifyTracks, listener.playlistMeta.Tracks...)
+// incrementSearches increments the number of searches made so far
+func incrementSearches() {
+	searchesCount := util.IncrementSearches(sharedCache.Backend)
+	log.Printf("Number of search so far: %d\n", searchesCount)
+}
+
+// GetAlbumListener listens for album action
+func (listener *SocketListener) GetAlbumListener() {
+	extracted, err := listener.extractInfo()
+	if err != nil {
+		log.Println("Error extracting")
+		log.Println(err)
+		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"error", "message":"Its me not you...."}`))
+		listener.c.Close()
+		return
 	}
 
-	conn := pool.Get()
-	defer conn.Close()
+	source, ok := platforms.Get(extracted.Host)
+	if !ok {
+		log.Println("Oops! Not a valid host")
+		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"Invalid host"}`))
+		listener.c.Close()
+		return
+	}
 
-	_, err = redis.String(conn.Do("GET", util.RedisSearchesKey))
+	conversion, err := playlistConverter.ConvertAlbum(context.Background(), source, extracted.ID)
 	if err != nil {
-		if err == redis.ErrNil {
-			_, err := redis.String(conn.Do("SET", util.RedisSearchesKey, "1"))
-			if err != nil {
-				log.Println("Error saving searches key into redis")
-			}
-		}
+		log.Printf("Error converting %s album.\n", source.Name())
+		log.Println(err)
+		listener.c.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"desc":"Error getting %s album"}`, source.Name())))
+		listener.c.Close()
+		return
 	}
 
-	searchesCount, err := redis.Int(conn.Do("INCR", util.RedisSearchesKey))
+	res := map[string]interface{}{
+		"action":  "album",
+		"payload": conversion,
+	}
+	listener.c.WriteJSON(res)
+	listener.c.Close()
+}
+
+// GetArtistListener listens for artist action
+func (listener *SocketListener) GetArtistListener() {
+	extracted, err := listener.extractInfo()
 	if err != nil {
-		log.Println("Error incrementing redis key")
+		log.Println("Error extracting")
+		log.Println(err)
+		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"error", "message":"Its me not you...."}`))
+		listener.c.Close()
+		return
 	}
-	log.Printf("Number of search so far: %d\n", searchesCount)
 
-	diff := 0
-	if len(listener.deezerTracks) > len(listener.spotifyTracks) {
-		diff = len(listener.deezerTracks) - len(listener.spotifyTracks)
-		listener.deezerTracks = listener.deezerTracks[:len(listener.deezerTracks)-diff]
-	} else if len(listener.spotifyTracks) > len(listener.deezerTracks) {
-		diff = len(listener.spotifyTracks) - len(listener.spotifyTracks)
-		listener.spotifyTracks = listener.spotifyTracks[:len(listener.spotifyTracks)-diff]
+	source, ok := platforms.Get(extracted.Host)
+	if !ok {
+		log.Println("Oops! Not a valid host")
+		listener.c.WriteMessage(websocket.TextMessage, []byte(`{"desc":"Invalid host"}`))
+		listener.c.Close()
+		return
 	}
 
-	for index, single := range listener.deezerTracks {
-		single.ReleaseDate = listener.spotifyTracks[index].ReleaseDate
+	conversion, err := playlistConverter.ConvertArtist(context.Background(), source, extracted.ID)
+	if err != nil {
+		log.Printf("Error converting %s artist.\n", source.Name())
+		log.Println(err)
+		listener.c.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"desc":"Error getting %s artist"}`, source.Name())))
+		listener.c.Close()
+		return
 	}
 
-	log.Println("Final deezer tracks are: ", listener.deezerTracks)
-	listener.tracks = append(listener.tracks, listener.deezerTracks, listener.spotifyTracks)
-	// log.Println("All tracks now are: ", listener.tracks)
-	// log.Println("Plalyist meta is: ", listener.playlistMeta)
 	res := map[string]interface{}{
-		"playlist_title": listener.playlistMeta.Title,
-		"payload":        listener.tracks,
-		"owner":          listener.playlistMeta.Owner,
-		"playlist_meta":  listener.playlistMeta,
-		"platforms": map[string]interface{}{
-			"spotify": listener.spotifyTracks,
-			"deezer":  listener.deezerTracks,
-		},
+		"action":  "artist",
+		"payload": conversion,
 	}
+	listener.c.WriteJSON(res)
+	listener.c.Close()
+}
 
+// GetConversionListener listens for convert action. It replies with the same response as the v2 API
+func (listener *SocketListener) GetConversionListener() {
+	extracted, err := listener.extractInfo()
+	if err != nil {
+		log.Println("Error extracting")
+		log.Println(err)
+		listener.c.WriteJSON(map[string]interface{}{"action": "convert", "desc": "error", "message": err.Error()})
+		listener.c.Close()
+		return
+	}
+
+	result, err := playlistConverter.Convert(context.Background(), extracted)
+	if err != nil {
+		log.Printf("Error converting %s %s.\n", extracted.Host, extracted.Type)
+		log.Println(err)
+		listener.c.WriteJSON(map[string]interface{}{"action": "convert", "desc": "error", "message": err.Error()})
+		listener.c.Close()
+		return
+	}
+
+	res := map[string]interface{}{
+		"action":  "convert",
+		"payload": result,
+	}
 	listener.c.WriteJSON(res)
-	listener.deezerTracks = nil
-	listener.spotifyTracks = nil
-	listener.tracks = nil
 	listener.c.Close()
 }
 
@@ -289,6 +358,28 @@ func (listener *SocketListener) CreatePlaylistListener() {
 	listener.c.Close()
 }
 
+// converterRoutes adds the routes converting the link in their query. The link middlewares are only added to them since
+// the other routes dont have a link (and would be a bad request without one).
+func converterRoutes(app *fiber.App, jaeger *controllers.Jaeger, linkResolver *middleware.LinkResolverMiddleware) {
+	app.Get("/api/v1.1/search", linkResolver.ResolveLink, middleware.ExtractedInfoMiddleware, jaeger.JaegerHandler)
+	app.Get("/api/v1.1/zoovify/playlist", linkResolver.ResolveLink, middleware.ExtractedInfoMiddleware, jaeger.ConvertPlaylist)
+	app.Get("/api/v1.1/zoovify/album", linkResolver.ResolveLink, middleware.ExtractedInfoMiddleware, jaeger.ConvertAlbum)
+	app.Get("/api/v1.1/zoovify/artist", linkResolver.ResolveLink, middleware.ExtractedInfoMiddleware, jaeger.ConvertArtist)
+	app.Get("/api/v2/convert", linkResolver.ResolveLink, middleware.ExtractedInfoMiddleware, jaeger.Convert)
+	app.Get("/api/v2/convert/stream", linkResolver.ResolveLink, middleware.ExtractedInfoMiddleware, jaeger.ConvertStream)
+}
+
+// profileRoutes adds the routes of the signed in user. They come last since every route after the jwt middleware needs
+// a user.
+func profileRoutes(app *fiber.App, jwtAuth fiber.Handler, authentication *middleware.AuthenticateMiddleware, userHandler *controllers.User) {
+	app.Use(jwtAuth)
+	app.Use(authentication.AuthenticateUser)
+	app.Get("/api/v1.1/me", userHandler.GetUserProfile)
+	app.Get("/api/v1.1/me/update", userHandler.UpdateUserProfile)
+	app.Get("/api/v1.1/me/history", userHandler.GetListeningHistory)
+	app.Get("/api/v1.1/me/history/artistes", userHandler.GetArtistePlayHistory)
+}
+
 func main() {
 	app := fiber.New()
 
@@ -307,9 +398,28 @@ func main() {
 		}
 	}()
 
-	userHandler := controllers.NewUserHandler(client, pool)
-	jaeger := controllers.NewJaeger(pool)
+	sharedCache = cache.New(cache.NewBackend())
+	platforms.TokenCache = sharedCache
+	userHandler := controllers.NewUserHandler(client, sharedCache)
 	authentication := middleware.NewAuthUserMiddleware(client)
+	resolver = util.NewLinkResolver(sharedCache.Backend)
+	playlistConverter = converter.NewConverter(sharedCache)
+	mappingStore := mappings.NewPrismaStore(client)
+	mappingStore.Cache = sharedCache
+	playlistConverter.Store = mappingStore
+	jaeger := controllers.NewJaeger(sharedCache, playlistConverter, resolver)
+	linkResolver := middleware.NewLinkResolverMiddleware(resolver)
+	jobQueue := jobs.NewQueue(sharedCache.Backend, playlistConverter)
+	webhookStore := webhooks.NewPrismaStore(client)
+	jobQueue.Webhooks = webhooks.NewDeliverer(webhookStore, webhookStore)
+	jobsHandler := controllers.NewJobs(jobQueue, resolver)
+	reportsHandler := controllers.NewReports(mappingStore, resolver)
+	jwtAuth := jwtware.New(
+		jwtware.Config{SigningKey: []byte(os.Getenv("JWT_SECRET")),
+			Claims:     &types.Token{},
+			ContextKey: "user",
+		})
+	go jobQueue.Work(context.Background(), jobs.Workers())
 
 	go loadListeners()
 
@@ -359,13 +469,6 @@ func main() {
 
 	app.Get("/api/v1.1/ws/connect", websocket.New(func(c *websocket.Conn) {
 		var tracks = [][]types.SingleTrack{}
-		var deezerTracks = []types.SingleTrack{}
-		var spotifyTracks = []types.SingleTrack{}
-		pool = &redis.Pool{
-			Dial: func() (redis.Conn, error) {
-				return redisurl.Connect()
-			},
-		}
 
 		register <- c
 		for {
@@ -388,16 +491,22 @@ func main() {
 			var trackMeta = &types.SingleTrack{}
 			var playlistMeta = &types.Playlist{}
 			listener := &SocketListener{deserialize: *deserialize,
-				c: c, client: client, deezerTracks: deezerTracks,
-				playlistMeta:  playlistMeta,
-				spotifyTracks: spotifyTracks,
-				trackMeta:     trackMeta,
-				tracks:        tracks,
+				c: c, client: client,
+				platformTracks: map[string][]*types.SingleTrack{},
+				playlistMeta:   playlistMeta,
+				trackMeta:      trackMeta,
+				tracks:         tracks,
 			}
 			if deserialize.Type == "track" {
 				listener.GetTrackListener()
 			} else if deserialize.Type == "playlist" {
 				listener.GetPlaylistListener()
+			} else if deserialize.Type == "album" {
+				listener.GetAlbumListener()
+			} else if deserialize.Type == "artist" {
+				listener.GetArtistListener()
+			} else if deserialize.Type == "convert" {
+				listener.GetConversionListener()
 			} else if deserialize.Type == "create_playlist" {
 				listener.CreatePlaylistListener()
 			} else {
@@ -405,24 +514,40 @@ func main() {
 			}
 		}
 	}))
+	app.Use("/api/v2/ws", func(ctx *fiber.Ctx) error {
+		if websocket.IsWebSocketUpgrade(ctx) {
+			ctx.Locals("allowed", true)
+			return ctx.Next()
+		}
+		return fiber.ErrUpgradeRequired
+	}, middleware.AuthenticateSocket)
+
+	// unlike v1.1, the connection stays open between requests (see docs/websocket.md)
+	app.Get("/api/v2/ws/connect", websocket.New(func(c *websocket.Conn) {
+		register <- c
+		uuid, _ := c.Locals("uuid").(string)
+		socket.NewSession(c, playlistConverter, resolver, client, uuid).Serve()
+	}))
 	app.Get("/:platform/signup", userHandler.SignupRedirect)
 	app.Get("/deezer/verify", userHandler.VerifyDeezerSignup)
 	app.Get("/kanye/:platform/oauth", userHandler.AuthorizeUser)
 	app.Post("/api/v1.1/user/join", userHandler.AddNewUser)
-	app.Use(middleware.ExtractedInfoMiddleware)
-	app.Get("/api/v1.1/search", jaeger.JaegerHandler)
-	app.Get("/api/v1.1/zoovify/playlist", jaeger.ConvertPlaylist)
-
-	app.Use(jwtware.New(
-		jwtware.Config{SigningKey: []byte(os.Getenv("JWT_SECRET")),
-			Claims:     &types.Token{},
-			ContextKey: "user",
-		}))
-	app.Use(authentication.AuthenticateUser)
-	app.Get("/api/v1.1/me", userHandler.GetUserProfile)
-	app.Get("/api/v1.1/me/update", userHandler.UpdateUserProfile)
-	app.Get("/api/v1.1/me/history", userHandler.GetListeningHistory)
-	app.Get("/api/v1.1/me/history/artistes", userHandler.GetArtistePlayHistory)
+	// the jobs and batch routes take the links in the body
+	app.Post("/api/v2/jobs", jobsHandler.CreateJob)
+	app.Get("/api/v2/jobs/:id", jobsHandler.GetJob)
+	app.Delete("/api/v2/jobs/:id", jobsHandler.CancelJob)
+	app.Post("/api/v2/convert/batch", jaeger.ConvertBatch)
+	// so do the reports, which need a user (and an admin to review them)
+	app.Post("/api/v2/reports", jwtAuth, authentication.AuthenticateUser, reportsHandler.CreateReport)
+	admin := app.Group("/api/v2/admin", jwtAuth, authentication.AuthenticateUser, authentication.RequireAdmin)
+	admin.Get("/reports", reportsHandler.GetReports)
+	admin.Post("/reports/:id/pin", reportsHandler.PinReport)
+	admin.Post("/reports/:id/dismiss", reportsHandler.DismissReport)
+	admin.Get("/overrides", reportsHandler.GetOverrides)
+	admin.Post("/overrides", reportsHandler.CreateOverride)
+	admin.Delete("/overrides/:id", reportsHandler.DeleteOverride)
+	converterRoutes(app, jaeger, linkResolver)
+	profileRoutes(app, jwtAuth, authentication, userHandler)
 
 	// app.Get("/api/v1.1/me/history")
 
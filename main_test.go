@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"zoove/cache"
+	"zoove/controllers"
+	"zoove/converter"
+	"zoove/middleware"
+	"zoove/types"
+	"zoove/util"
+
+	"github.com/gofiber/fiber/v2"
+	jwtware "github.com/gofiber/jwt/v2"
+)
+
+func TestProfileRoutesDontNeedALink(t *testing.T) {
+	store := cache.New(cache.NewMemoryBackend(10))
+	resolver := util.NewLinkResolver(nil)
+	app := fiber.New()
+	converterRoutes(app, controllers.NewJaeger(store, converter.NewConverter(store), resolver), middleware.NewLinkResolverMiddleware(resolver))
+	jwtAuth := jwtware.New(jwtware.Config{SigningKey: []byte("secret"), Claims: &types.Token{}, ContextKey: "user"})
+	profileRoutes(app, jwtAuth, middleware.NewAuthUserMiddleware(nil), controllers.NewUserHandler(nil, store))
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		// the jwt is checked (and isnt valid) instead of the link
+		{path: "/api/v1.1/me", expected: http.StatusUnauthorized},
+		{path: "/api/v1.1/me/history", expected: http.StatusUnauthorized},
+		{path: "/api/v1.1/search", expected: http.StatusBadRequest},
+		{path: "/api/v2/convert", expected: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.Header.Set("Authorization", "Bearer invalid")
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.path, test.expected, res.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package mappings keeps the tracks that have been matched across platforms in the database (see converter.Store).
+// Each track is a CanonicalTrack and its ID on each platform is a PlatformTrack.
+package mappings
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"zoove/cache"
+	"zoove/db"
+	"zoove/types"
+	"zoove/util"
+)
+
+// PrismaStore is a converter.Store that keeps the mappings in the database. Cache (optional) is the cache of the
+// converter, whose conversions of the tracks of an override are removed when it is pinned or removed.
+type PrismaStore struct {
+	DB    *db.PrismaClient
+	Cache *cache.Cache
+}
+
+// NewPrismaStore returns a new PrismaStore
+func NewPrismaStore(client *db.PrismaClient) *PrismaStore {
+	return &PrismaStore{DB: client}
+}
+
+// Lookup returns the tracks on the other platforms that are the same as the track. The confidence of each is the lowest
+// of the confidences of the two tracks (the track converted at first has a confidence of 1), and the tracks below
+// cache.ReverseConfidence are left out (see sure). Overrides pinned by admins take the place of the saved tracks.
+func (store *PrismaStore) Lookup(ctx context.Context, track *types.SingleTrack) (map[string]*types.TrackMapping, error) {
+	mappings, err := store.overrides(ctx, track)
+	if err != nil {
+		return nil, err
+	}
+	canonicalID, confidence, err := store.canonical(ctx, track)
+	if err != nil {
+		return nil, err
+	}
+	if canonicalID == 0 {
+		return mappings, nil
+	}
+	rows, err := store.DB.PlatformTrack.FindMany(db.PlatformTrack.CanonicalID.Equals(canonicalID)).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if _, ok := mappings[row.Platform]; ok || row.Platform == track.Platform {
+			continue
+		}
+		// saved before only sure matches were
+		if math.Min(row.Confidence, confidence) < cache.ReverseConfidence {
+			continue
+		}
+		mappings[row.Platform] = &types.TrackMapping{Platform: row.Platform, PlatformID: row.PlatformID, ISRC: row.ISRC,
+			Confidence: math.Min(row.Confidence, confidence), Source: row.Source, MatchedAt: row.MatchedAt}
+	}
+	return mappings, nil
+}
+
+// Save saves the tracks of a conversion as the same (canonical) track. Tracks that have already been saved are skipped,
+// and so are the tracks that werent matched surely enough (see sure) since the saved tracks are used both ways and
+// never expire. When the tracks were saved as different canonical tracks (e.g two conversions of the track saved it at the same
+// time), they are all moved to the canonical track with the lowest ID, so every conversion settles on the same one.
+func (store *PrismaStore) Save(ctx context.Context, conversion *types.TrackConversion) error {
+	platforms := make([]string, 0, len(conversion.Tracks))
+	var source *types.SingleTrack
+	for platform, track := range conversion.Tracks {
+		if track == nil {
+			continue
+		}
+		if track.MatchStrategy == "" {
+			source = track
+		} else if !sure(track) {
+			continue
+		}
+		platforms = append(platforms, platform)
+	}
+	if source == nil || len(platforms) < 2 {
+		return nil
+	}
+	sort.Strings(platforms)
+
+	canonicals := map[string]int{}
+	for _, platform := range platforms {
+		rows, err := store.DB.PlatformTrack.FindMany(db.PlatformTrack.Platform.Equals(platform), db.PlatformTrack.PlatformID.Equals(conversion.Tracks[platform].ID)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			canonicals[platform] = rows[0].CanonicalID
+		}
+	}
+
+	now := time.Now()
+	canonicalID := lowest(canonicals)
+	if canonicalID == 0 {
+		isrc := source.ISRC
+		for _, platform := range platforms {
+			if isrc == "" {
+				isrc = conversion.Tracks[platform].ISRC
+			}
+		}
+		canonical, err := store.DB.CanonicalTrack.CreateOne(
+			db.CanonicalTrack.UpdatedAt.Set(now),
+			db.CanonicalTrack.ISRC.Set(isrc),
+			db.CanonicalTrack.Title.Set(source.Title),
+			db.CanonicalTrack.Artistes.Set(strings.Join(source.Artistes, ", ")),
+		).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		canonicalID = canonical.ID
+	}
+
+	for _, platform := range platforms {
+		if _, ok := canonicals[platform]; ok {
+			continue
+		}
+		track := conversion.Tracks[platform]
+		strategy, confidence := track.MatchStrategy, track.Confidence
+		if track == source {
+			strategy, confidence = util.MatchStrategySource, 1
+		}
+		saved, err := store.upsert(ctx, canonicalID, platform, track, strategy, confidence, now)
+		if err != nil {
+			return err
+		}
+		canonicals[platform] = saved
+	}
+
+	canonicalID = lowest(canonicals)
+	for _, platform := range platforms {
+		if canonicals[platform] == canonicalID {
+			continue
+		}
+		var moved []platformTrackRow
+		err := store.DB.QueryRaw(`UPDATE "PlatformTrack" SET "canonicalId" = $1 WHERE "platform" = $2 AND "platformId" = $3 RETURNING "canonicalId"`,
+			canonicalID, platform, conversion.Tracks[platform].ID).Exec(ctx, &moved)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sure returns true if the track was matched surely enough to be saved: by ISRC, by an override or with at least
+// cache.ReverseConfidence, like the conversions that are cached both ways.
+func sure(track *types.SingleTrack) bool {
+	return track.MatchStrategy == util.MatchStrategyISRC || track.MatchStrategy == util.MatchStrategyOverride ||
+		track.Confidence >= cache.ReverseConfidence
+}
+
+// platformTrackRow is a PlatformTrack returned by a raw query
+type platformTrackRow struct {
+	CanonicalID int `json:"canonicalId"`
+}
+
+// upsert saves the track on the platform as the canonical track with the ID, unless it has been saved since it was
+// looked up. It returns the ID of the canonical track the track is saved as.
+func (store *PrismaStore) upsert(ctx context.Context, canonicalID int, platform string, track *types.SingleTrack, strategy string, confidence float64, now time.Time) (int, error) {
+	var rows []platformTrackRow
+	err := store.DB.QueryRaw(`INSERT INTO "PlatformTrack"("canonicalId", "isrc", "platform", "platformId", "confidence", "matchedAt", "source")
+	VALUES($1, $2, $3, $4, $5, $6, $7) ON CONFLICT ("platform", "platformId") DO UPDATE SET "platform" = EXCLUDED."platform"
+	RETURNING "canonicalId"`,
+		canonicalID, track.ISRC, platform, track.ID, confidence, now, strategy).Exec(ctx, &rows)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return canonicalID, nil
+	}
+	return rows[0].CanonicalID, nil
+}
+
+// lowest returns the lowest of the IDs, 0 when there's none
+func lowest(ids map[string]int) int {
+	lowest := 0
+	for _, id := range ids {
+		if lowest == 0 || id < lowest {
+			lowest = id
+		}
+	}
+	return lowest
+}
+
+// canonical returns the ID of the canonical track of a track (0 when it has none) and how sure we are that it is the
+// track. Tracks that havent been saved are matched with a canonical track with the same ISRC.
+func (store *PrismaStore) canonical(ctx context.Context, track *types.SingleTrack) (int, float64, error) {
+	rows, err := store.DB.PlatformTrack.FindMany(db.PlatformTrack.Platform.Equals(track.Platform), db.PlatformTrack.PlatformID.Equals(track.ID)).Exec(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(rows) > 0 {
+		return rows[0].CanonicalID, rows[0].Confidence, nil
+	}
+	if track.ISRC == "" {
+		return 0, 0, nil
+	}
+	canonicals, err := store.DB.CanonicalTrack.FindMany(db.CanonicalTrack.ISRC.Equals(track.ISRC)).Exec(ctx)
+	if err != nil || len(canonicals) == 0 {
+		return 0, 0, err
+	}
+	// the same ISRC is the same recording
+	return canonicals[0].ID, 1, nil
+}
@@ -0,0 +1,297 @@
+package mappings
+
+import (
+	"context"
+	"log"
+	"time"
+	"zoove/db"
+	"zoove/errors"
+	"zoove/types"
+	"zoove/util"
+)
+
+// The status of a match report
+const (
+	ReportOpen = "open"
+	// ReportPinned means an override was pinned for the match
+	ReportPinned    = "pinned"
+	ReportDismissed = "dismissed"
+)
+
+// Report saves a user's report that a track was converted to the wrong track
+func (store *PrismaStore) Report(ctx context.Context, report *types.MatchReport) (*types.MatchReport, error) {
+	row, err := store.DB.MatchReport.CreateOne(
+		db.MatchReport.UpdatedAt.Set(time.Now()),
+		db.MatchReport.Reporter.Link(db.User.UUID.Equals(report.UserUUID)),
+		db.MatchReport.SourcePlatform.Set(report.SourcePlatform),
+		db.MatchReport.SourceID.Set(report.SourceID),
+		db.MatchReport.TargetPlatform.Set(report.TargetPlatform),
+		db.MatchReport.TargetID.Set(report.TargetID),
+		db.MatchReport.CorrectedID.Set(report.CorrectedID),
+		db.MatchReport.Status.Set(ReportOpen),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	saved := newMatchReport(row)
+	open, err := store.DB.MatchReport.FindMany(
+		db.MatchReport.SourcePlatform.Equals(row.SourcePlatform),
+		db.MatchReport.SourceID.Equals(row.SourceID),
+		db.MatchReport.TargetPlatform.Equals(row.TargetPlatform),
+		db.MatchReport.TargetID.Equals(row.TargetID),
+		db.MatchReport.Status.Equals(ReportOpen),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	saved.Reports = len(open)
+	return saved, nil
+}
+
+// matchReportRow is a MatchReport returned by a raw query, with the number of open reports of the same match
+type matchReportRow struct {
+	db.MatchReportModel
+	Reports int `json:"reports"`
+}
+
+// Reports returns limit of the reports with the status (all of them when it is empty), skipping the first offset. The
+// matches reported the most come first. The open reports of each match are counted by the database.
+func (store *PrismaStore) Reports(ctx context.Context, status string, limit, offset int) ([]*types.MatchReport, error) {
+	var rows []matchReportRow
+	err := store.DB.QueryRaw(`SELECT report.*, (SELECT COUNT(*) FROM "MatchReport" AS same WHERE same."status" = $1
+	AND same."sourcePlatform" = report."sourcePlatform" AND same."sourceId" = report."sourceId"
+	AND same."targetPlatform" = report."targetPlatform" AND same."targetId" = report."targetId")::integer AS "reports"
+	FROM "MatchReport" AS report WHERE $2 = '' OR report."status" = $2
+	ORDER BY "reports" DESC, report."createdAt", report."id" LIMIT $3 OFFSET $4`,
+		ReportOpen, status, limit, offset).Exec(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*types.MatchReport, len(rows))
+	for index, row := range rows {
+		reports[index] = newMatchReport(row.MatchReportModel)
+		reports[index].Reports = row.Reports
+	}
+	return reports, nil
+}
+
+// GetReport returns the report with the ID. It returns errors.NotFound if there is no such report
+func (store *PrismaStore) GetReport(ctx context.Context, id int) (*types.MatchReport, error) {
+	row, err := store.DB.MatchReport.FindOne(db.MatchReport.ID.Equals(id)).Exec(ctx)
+	if err == db.ErrNotFound {
+		return nil, errors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newMatchReport(row), nil
+}
+
+// Dismiss marks a report as reviewed without pinning an override
+func (store *PrismaStore) Dismiss(ctx context.Context, id int, admin string) (*types.MatchReport, error) {
+	if _, err := store.GetReport(ctx, id); err != nil {
+		return nil, err
+	}
+	row, err := store.DB.MatchReport.FindOne(db.MatchReport.ID.Equals(id)).Update(
+		db.MatchReport.UpdatedAt.Set(time.Now()),
+		db.MatchReport.Status.Set(ReportDismissed),
+		db.MatchReport.Reviewer.Link(db.User.UUID.Equals(admin)),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newMatchReport(row), nil
+}
+
+// Pin pins the override, replacing the one of the source track on the target platform if there is one. The open
+// reports of the source track on the target platform are marked as pinned, and the wrong mappings the converter saved
+// are removed so that the wrong tracks are searched for again.
+func (store *PrismaStore) Pin(ctx context.Context, override *types.MappingOverride, admin string) (*types.MappingOverride, error) {
+	existing, err := store.DB.MappingOverride.FindMany(
+		db.MappingOverride.SourcePlatform.Equals(override.SourcePlatform),
+		db.MappingOverride.SourceID.Equals(override.SourceID),
+		db.MappingOverride.TargetPlatform.Equals(override.TargetPlatform),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var row db.MappingOverrideModel
+	if len(existing) > 0 {
+		row, err = store.DB.MappingOverride.FindOne(db.MappingOverride.ID.Equals(existing[0].ID)).Update(
+			db.MappingOverride.UpdatedAt.Set(now),
+			db.MappingOverride.TargetID.Set(override.TargetID),
+			db.MappingOverride.UpdatedBy.Set(admin),
+		).Exec(ctx)
+	} else {
+		row, err = store.DB.MappingOverride.CreateOne(
+			db.MappingOverride.UpdatedAt.Set(now),
+			db.MappingOverride.SourcePlatform.Set(override.SourcePlatform),
+			db.MappingOverride.SourceID.Set(override.SourceID),
+			db.MappingOverride.TargetPlatform.Set(override.TargetPlatform),
+			db.MappingOverride.TargetID.Set(override.TargetID),
+			db.MappingOverride.CreatedBy.Set(admin),
+			db.MappingOverride.UpdatedBy.Set(admin),
+			db.MappingOverride.Reports.Set(0),
+		).Exec(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reports, err := store.DB.MatchReport.FindMany(
+		db.MatchReport.SourcePlatform.Equals(override.SourcePlatform),
+		db.MatchReport.SourceID.Equals(override.SourceID),
+		db.MatchReport.TargetPlatform.Equals(override.TargetPlatform),
+		db.MatchReport.Status.Equals(ReportOpen),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, report := range reports {
+		_, err = store.DB.MatchReport.FindOne(db.MatchReport.ID.Equals(report.ID)).Update(
+			db.MatchReport.UpdatedAt.Set(now),
+			db.MatchReport.Status.Set(ReportPinned),
+			db.MatchReport.Reviewer.Link(db.User.UUID.Equals(admin)),
+			db.MatchReport.Override.Link(db.MappingOverride.ID.Equals(row.ID)),
+		).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(reports) > 0 {
+		row, err = store.DB.MappingOverride.FindOne(db.MappingOverride.ID.Equals(row.ID)).Update(
+			db.MappingOverride.Reports.Set(row.Reports + len(reports)),
+		).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = store.unmap(ctx, override)
+	if err != nil {
+		return nil, err
+	}
+	store.forget(override)
+	return newMappingOverride(row), nil
+}
+
+// Overrides returns the pinned overrides
+func (store *PrismaStore) Overrides(ctx context.Context) ([]*types.MappingOverride, error) {
+	rows, err := store.DB.MappingOverride.FindMany().Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make([]*types.MappingOverride, len(rows))
+	for index, row := range rows {
+		overrides[index] = newMappingOverride(row)
+	}
+	return overrides, nil
+}
+
+// Unpin removes the override with the ID. It returns errors.NotFound if there is no such override
+func (store *PrismaStore) Unpin(ctx context.Context, id int) (*types.MappingOverride, error) {
+	row, err := store.DB.MappingOverride.FindOne(db.MappingOverride.ID.Equals(id)).Delete().Exec(ctx)
+	if err == db.ErrNotFound {
+		return nil, errors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	removed := newMappingOverride(row)
+	store.forget(removed)
+	return removed, nil
+}
+
+// forget removes the cached conversions of the tracks of the override, both ways, so that they arent converted to the
+// tracks they were converted to before it was pinned (or removed). The playlist conversions from before are converted
+// again (see cache.OverridesChanged).
+func (store *PrismaStore) forget(override *types.MappingOverride) {
+	if store.Cache == nil {
+		return
+	}
+	// the conversions of the playlists the tracks are in are cached too
+	if err := store.Cache.SetOverridesChanged(time.Now()); err != nil {
+		log.Println("Error saving when the overrides changed")
+		log.Println(err)
+	}
+	conversions := [][3]string{
+		{override.SourcePlatform, override.SourceID, override.TargetPlatform},
+		{override.TargetPlatform, override.TargetID, override.SourcePlatform},
+	}
+	for _, conversion := range conversions {
+		err := store.Cache.DeleteConversion(conversion[0], conversion[1], conversion[2])
+		if err != nil {
+			log.Printf("Error removing the cached conversion of %s track %s\n", conversion[0], conversion[1])
+			log.Println(err)
+		}
+	}
+}
+
+// overrides returns the overrides of a track keyed by platform. Overrides work both ways, so the source track of an
+// override is the override of its target track.
+func (store *PrismaStore) overrides(ctx context.Context, track *types.SingleTrack) (map[string]*types.TrackMapping, error) {
+	from, err := store.DB.MappingOverride.FindMany(db.MappingOverride.SourcePlatform.Equals(track.Platform),
+		db.MappingOverride.SourceID.Equals(track.ID)).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	to, err := store.DB.MappingOverride.FindMany(db.MappingOverride.TargetPlatform.Equals(track.Platform),
+		db.MappingOverride.TargetID.Equals(track.ID)).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]*types.TrackMapping{}
+	for _, row := range to {
+		overrides[row.SourcePlatform] = &types.TrackMapping{Platform: row.SourcePlatform, PlatformID: row.SourceID,
+			Confidence: 1, Source: util.MatchStrategyOverride, MatchedAt: row.UpdatedAt}
+	}
+	// an override pinned for the track itself wins over one it is the target of
+	for _, row := range from {
+		overrides[row.TargetPlatform] = &types.TrackMapping{Platform: row.TargetPlatform, PlatformID: row.TargetID,
+			Confidence: 1, Source: util.MatchStrategyOverride, MatchedAt: row.UpdatedAt}
+	}
+	return overrides, nil
+}
+
+// unmap removes the tracks on the target platform that were saved as the same as the source track of the override
+func (store *PrismaStore) unmap(ctx context.Context, override *types.MappingOverride) error {
+	canonicalID, _, err := store.canonical(ctx, &types.SingleTrack{Platform: override.SourcePlatform, ID: override.SourceID})
+	if err != nil || canonicalID == 0 {
+		return err
+	}
+	rows, err := store.DB.PlatformTrack.FindMany(db.PlatformTrack.CanonicalID.Equals(canonicalID),
+		db.PlatformTrack.Platform.Equals(override.TargetPlatform)).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.PlatformID == override.TargetID {
+			continue
+		}
+		_, err = store.DB.PlatformTrack.FindOne(db.PlatformTrack.ID.Equals(row.ID)).Delete().Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newMatchReport(row db.MatchReportModel) *types.MatchReport {
+	report := &types.MatchReport{ID: row.ID, UserUUID: row.UserUUID, SourcePlatform: row.SourcePlatform, SourceID: row.SourceID,
+		TargetPlatform: row.TargetPlatform, TargetID: row.TargetID, CorrectedID: row.CorrectedID, Status: row.Status,
+		CreatedAt: row.CreatedAt, UpdatedAt: row.UpdatedAt}
+	// they are only set once the report has been reviewed
+	report.ReviewedBy, _ = row.ReviewedBy()
+	report.OverrideID, _ = row.OverrideID()
+	return report
+}
+
+func newMappingOverride(row db.MappingOverrideModel) *types.MappingOverride {
+	return &types.MappingOverride{ID: row.ID, SourcePlatform: row.SourcePlatform, SourceID: row.SourceID,
+		TargetPlatform: row.TargetPlatform, TargetID: row.TargetID, CreatedBy: row.CreatedBy, UpdatedBy: row.UpdatedBy,
+		Reports: row.Reports, CreatedAt: row.CreatedAt, UpdatedAt: row.UpdatedAt}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"os"
 	"zoove/db"
+	"zoove/errors"
 	"zoove/types"
 	"zoove/util"
 
@@ -12,8 +14,18 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// linkQuery returns the (unescaped) link in the request. v1.1 routes send it as "track" and v2 routes as "url". It is
+// the only place the link is unescaped, unescaping it again would change links with an escaped "%" or "+" in them.
+func linkQuery(ctx *fiber.Ctx) string {
+	return ctx.Query("url", ctx.Query("track"))
+}
+
 func ExtractedInfoMiddleware(ctx *fiber.Ctx) error {
-	rawURL := ctx.Query("track")
+	rawURL := linkQuery(ctx)
+	// set by ResolveLink when the link is a share link
+	if resolved, ok := ctx.Locals("resolvedURL").(string); ok {
+		rawURL = resolved
+	}
 	extracted, err := util.ExtractInfoMetadata(rawURL)
 	if err != nil {
 		log.Println("Error extracting metadata info")
@@ -44,6 +56,65 @@ func (auth *AuthenticateMiddleware) AuthenticateUser(ctx *fiber.Ctx) error {
 	return ctx.Next()
 }
 
+// RequireAdmin only lets admins through. It should be used after AuthenticateUser
+func (auth *AuthenticateMiddleware) RequireAdmin(ctx *fiber.Ctx) error {
+	uuid, _ := ctx.Locals("uuid").(string)
+	user, err := auth.DB.User.FindOne(db.User.UUID.Equals(uuid)).Exec(context.Background())
+	if err != nil {
+		if err == db.ErrNotFound {
+			return util.RequestUnAuthorized(ctx, errors.UnAuthorized)
+		}
+		log.Println("Error getting user from DB")
+		log.Println(err)
+		return util.InternalServerError(ctx, err)
+	}
+	if !user.IsAdmin {
+		return util.Forbidden(ctx, errors.Forbidden)
+	}
+	return ctx.Next()
+}
+
 func NewAuthUserMiddleware(db *db.PrismaClient) *AuthenticateMiddleware {
 	return &AuthenticateMiddleware{DB: db}
 }
+
+// AuthenticateSocket authenticates a websocket connection with the jwt in its token query (browsers cant set the headers
+// of websocket connections) and sets the uuid of its user. Connections without a token are let through without a user.
+func AuthenticateSocket(ctx *fiber.Ctx) error {
+	value := ctx.Query("token")
+	if value == "" {
+		return ctx.Next()
+	}
+	claims, err := util.ParseJwtToken(value, os.Getenv("JWT_SECRET"))
+	if err != nil {
+		return util.RequestUnAuthorized(ctx, err)
+	}
+	ctx.Locals("uuid", claims.UUID)
+	return ctx.Next()
+}
+
+// LinkResolverMiddleware resolves share links (e.g deezer.page.link) to the links of the platforms
+type LinkResolverMiddleware struct {
+	Resolver *util.LinkResolver
+}
+
+// ResolveLink resolves the link in the request if it is a share link. It should be used before ExtractedInfoMiddleware
+func (middleware *LinkResolverMiddleware) ResolveLink(ctx *fiber.Ctx) error {
+	rawURL := linkQuery(ctx)
+	if !middleware.Resolver.IsShortLink(rawURL) {
+		return ctx.Next()
+	}
+
+	resolved, err := middleware.Resolver.Resolve(rawURL)
+	if err != nil {
+		log.Println("Error resolving short link")
+		log.Println(err)
+		return util.BadRequest(ctx, err)
+	}
+	ctx.Locals("resolvedURL", resolved)
+	return ctx.Next()
+}
+
+func NewLinkResolverMiddleware(resolver *util.LinkResolver) *LinkResolverMiddleware {
+	return &LinkResolverMiddleware{Resolver: resolver}
+}
@@ -0,0 +1,135 @@
+// Package normalize cleans up track titles and artiste names so that the same track compares (and searches) the same
+// on every platform. e.g "Essence (feat. Tems)", "Essence - Remastered 2011" and "ＥＳＳＥＮＣＥ" all have the Title "essence".
+package normalize
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+var (
+	// (feat. X), [ft X], (featuring X), (with X)
+	featuringPattern = regexp.MustCompile(`(?i)^\s*(feat\.?|ft\.?|featuring|with)\s+`)
+	// feat. X without brackets, e.g "Essence feat. Tems"
+	bareFeaturingPattern = regexp.MustCompile(`(?i)\s+(feat\.?|ft\.?|featuring)\s+.*$`)
+	// a bracketed part of a title, e.g (Live) or [Radio Edit]
+	bracketPattern = regexp.MustCompile(`\s*[\(\[]([^\(\)\[\]]*)[\)\]]`)
+	// the dash separated suffix of a title, e.g " - Remastered 2011". it is after the last dash so that "Song - Live -
+	// 2011 Remaster" only has "2011 Remaster" as its suffix
+	dashPattern = regexp.MustCompile(`^(.*\S)\s+[-–—]\s+(.*)$`)
+	// the separators in an artiste credit, e.g "Wizkid feat. Tems, Justin Bieber & Burna Boy". "and" is the same as "&"
+	artisteSeparatorPattern = regexp.MustCompile(`(?i)\s*(?:,|;|/|\s&\s|\sand\s|\sx\s|\sfeat\.?\s|\sft\.?\s|\sfeaturing\s|\swith\s)\s*`)
+
+	// versionPattern matches every kind of version of a track. they are removed from titles before searching. a year
+	// alone (e.g "(1999)") isnt a version, only next to one (e.g "2011 Remaster")
+	versionPattern = regexp.MustCompile(`(?i)\b(remaster(ed)?|live|edit|version|mix|remix|mono|stereo|acoustic|demo|instrumental|karaoke|explicit|clean|single|deluxe|bonus|session|mixed)\b`)
+	// sameRecordingPattern matches the versions that are (practically) the same recording as the original. they are
+	// removed when comparing titles. others like live, remix and karaoke are kept so that they dont match the original.
+	sameRecordingPattern = regexp.MustCompile(`(?i)^\s*((\d{4}\s+)?(digital(ly)?\s+)?remaster(ed)?(\s+\d{4})?(\s+version)?|(radio|single|album)\s+(edit|version)|edit|original( mix| version)?|mono|stereo|explicit|clean)\s*$`)
+
+	accents = transform.Chain(width.Fold, norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+	// letters that dont decompose into a base letter and an accent
+	letters = strings.NewReplacer("ø", "o", "Ø", "O", "ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE", "đ", "d", "Đ", "D", "ł", "l", "Ł", "L", "ı", "i")
+)
+
+// SearchTitle returns the title to search for on a platform. It removes the featured artistes and the version
+// (remastered, live, radio edit, etc) from the title but leaves the rest of it as it is.
+func SearchTitle(title string) string {
+	title = bareFeaturingPattern.ReplaceAllString(title, "")
+	return strings.TrimSpace(strip(title, func(part string) bool {
+		return featuringPattern.MatchString(part) || versionPattern.MatchString(part)
+	}))
+}
+
+// Title returns the title to compare with titles of other tracks. It removes the featured artistes and the versions that
+// are the same recording (remastered, radio edit, etc) and returns the Key of what is left.
+func Title(title string) string {
+	title = bareFeaturingPattern.ReplaceAllString(title, "")
+	return Key(strip(title, func(part string) bool {
+		return featuringPattern.MatchString(part) || sameRecordingPattern.MatchString(part)
+	}))
+}
+
+// Artiste returns the artiste name to compare with names of other artistes
+func Artiste(name string) string {
+	return Key(name)
+}
+
+// SplitArtistes splits an artiste credit (e.g "Wizkid feat. Tems & Justin Bieber") into the Artiste of each artiste
+func SplitArtistes(credit string) []string {
+	artistes := []string{}
+	for _, name := range artisteSeparatorPattern.Split(credit, -1) {
+		if key := Artiste(name); key != "" {
+			artistes = append(artistes, key)
+		}
+	}
+	return artistes
+}
+
+// Featured returns the artistes featured in a title, e.g "Essence (feat. Tems)" returns ["tems"]
+func Featured(title string) []string {
+	featured := []string{}
+	parts := bracketPattern.FindAllStringSubmatch(title, -1)
+	if dash := dashPattern.FindStringSubmatch(title); dash != nil {
+		parts = append(parts, []string{dash[0], dash[2]})
+	}
+	if bare := bareFeaturingPattern.FindString(title); bare != "" {
+		parts = append(parts, []string{bare, bare})
+	}
+	// a dash suffix like " - feat. Tems" is also a bare featuring so the artistes are only added once
+	seen := map[string]bool{}
+	for _, part := range parts {
+		if loc := featuringPattern.FindStringIndex(part[1]); loc != nil {
+			for _, artiste := range SplitArtistes(part[1][loc[1]:]) {
+				if !seen[artiste] {
+					seen[artiste] = true
+					featured = append(featured, artiste)
+				}
+			}
+		}
+	}
+	return featured
+}
+
+// Key returns the bare form of a title or name: full-width characters and diacritics folded, lowercased, "&" turned to
+// "and", punctuation removed and without a leading "the".
+func Key(value string) string {
+	folded, _, err := transform.String(accents, value)
+	if err == nil {
+		value = folded
+	}
+	value = strings.ToLower(letters.Replace(value))
+	value = strings.ReplaceAll(value, "&", " and ")
+
+	words := strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) > 1 && words[0] == "the" {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// strip removes the bracketed parts and the dash suffix of a title that remove says should be removed
+func strip(title string, remove func(part string) bool) string {
+	title = bracketPattern.ReplaceAllStringFunc(title, func(part string) string {
+		content := bracketPattern.FindStringSubmatch(part)[1]
+		if remove(content) {
+			return ""
+		}
+		return part
+	})
+	if dash := dashPattern.FindStringSubmatchIndex(title); dash != nil {
+		if remove(title[dash[4]:dash[5]]) {
+			title = title[:dash[3]]
+		}
+	}
+	return title
+}
@@ -0,0 +1,106 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected string
+	}{
+		{title: "Essence", expected: "essence"},
+		{title: "Essence (feat. Tems)", expected: "essence"},
+		{title: "Essence [ft Tems]", expected: "essence"},
+		{title: "Essence feat. Tems", expected: "essence"},
+		{title: "Essence - Remastered 2011", expected: "essence"},
+		{title: "Essence (2011 Remaster)", expected: "essence"},
+		{title: "Essence - Radio Edit", expected: "essence"},
+
+		// diacritics and full-width characters
+		{title: "Beyoncé", expected: "beyonce"},
+		{title: "Mañana Será Bonito", expected: "manana sera bonito"},
+		{title: "Ｅｓｓｅｎｃｅ", expected: "essence"},
+		{title: "Søren", expected: "soren"},
+
+		// & and "and"
+		{title: "Rock & Roll", expected: "rock and roll"},
+		{title: "Rock and Roll", expected: "rock and roll"},
+
+		// only the last dash is the suffix
+		{title: "Intro - Skit - 2011 Remaster", expected: "intro skit"},
+		{title: "Intro - Skit", expected: "intro skit"},
+
+		// a year alone isnt a version
+		{title: "Party Like It's (1999)", expected: "party like it s 1999"},
+		{title: "Live - 2012 Tour", expected: "live 2012 tour"},
+
+		// other versions are a different recording
+		{title: "Essence (Live)", expected: "essence live"},
+		{title: "Essence - Remix", expected: "essence remix"},
+	}
+	for _, test := range tests {
+		if got := Title(test.title); got != test.expected {
+			t.Errorf("Title(%q): expected %q, got %q", test.title, test.expected, got)
+		}
+	}
+}
+
+func TestSearchTitle(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected string
+	}{
+		{title: "Essence (feat. Tems)", expected: "Essence"},
+		{title: "Essence (Live)", expected: "Essence"},
+		{title: "Intro - Skit - 2011 Remaster", expected: "Intro - Skit"},
+		{title: "Party Like It's (1999)", expected: "Party Like It's (1999)"},
+		{title: "Live - 2012 Tour", expected: "Live - 2012 Tour"},
+		{title: "Essence - Live at the O2", expected: "Essence"},
+	}
+	for _, test := range tests {
+		if got := SearchTitle(test.title); got != test.expected {
+			t.Errorf("SearchTitle(%q): expected %q, got %q", test.title, test.expected, got)
+		}
+	}
+}
+
+func TestSplitArtistes(t *testing.T) {
+	tests := []struct {
+		credit   string
+		expected string
+	}{
+		{credit: "Wizkid", expected: "wizkid"},
+		{credit: "Wizkid feat. Tems", expected: "wizkid|tems"},
+		{credit: "Wizkid ft Tems, Justin Bieber", expected: "wizkid|tems|justin bieber"},
+		{credit: "Simon & Garfunkel", expected: "simon|garfunkel"},
+		{credit: "Simon and Garfunkel", expected: "simon|garfunkel"},
+		{credit: "Burna Boy x Ed Sheeran", expected: "burna boy|ed sheeran"},
+		{credit: "The Weeknd", expected: "weeknd"},
+		{credit: "Sigur Rós", expected: "sigur ros"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(SplitArtistes(test.credit), "|"); got != test.expected {
+			t.Errorf("SplitArtistes(%q): expected %q, got %q", test.credit, test.expected, got)
+		}
+	}
+}
+
+func TestFeatured(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected string
+	}{
+		{title: "Essence", expected: ""},
+		{title: "Essence (feat. Tems)", expected: "tems"},
+		{title: "Essence [with Justin Bieber & Tems]", expected: "justin bieber|tems"},
+		{title: "Essence feat. Tems and Justin Bieber", expected: "tems|justin bieber"},
+		{title: "Intro - Skit - feat. Tems", expected: "tems"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(Featured(test.title), "|"); got != test.expected {
+			t.Errorf("Featured(%q): expected %q, got %q", test.title, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package platforms
+
+import (
+	"strings"
+	"zoove/types"
+	"zoove/util"
+)
+
+// AlbumTrackMinConfidence is the min confidence for a track to be matched with a track of the same album on another platform.
+// Below it, the tracks of an album are too alike (same artiste, album and similar durations) to be sure.
+const AlbumTrackMinConfidence = 0.6
+
+// MatchAlbumTrack returns the track on the album that is the same as track, or nil if there is none (or no album).
+func MatchAlbumTrack(track *types.SingleTrack, album *types.Album) *types.SingleTrack {
+	if album == nil {
+		return nil
+	}
+
+	// the tracks of the albums fetched from the platforms dont have their ISRC, so they are matched by their title,
+	// artistes and duration
+	search := NewTrackToSearchFromTrack(track, nil)
+	// all the tracks are on the same album, comparing it would only make the wrong tracks score higher
+	search.Album = ""
+	best, err := search.RankCandidates(album.Tracks)
+	if err != nil || best.Confidence < AlbumTrackMinConfidence {
+		return nil
+	}
+	best.Alternatives = nil
+	best.MatchStrategy = util.MatchStrategyAlbum
+	return best
+}
+
+// UPCVariants returns the forms a UPC could take on the platforms. Some platforms return the 13 digit EAN (with a leading
+// zero) of a 12 digit UPC, and some return the UPC.
+func UPCVariants(upc string) []string {
+	if upc == "" {
+		return nil
+	}
+	variants := []string{upc}
+	trimmed := strings.TrimLeft(upc, "0")
+	if len(trimmed) == 12 && trimmed != upc {
+		variants = append(variants, trimmed)
+	}
+	if len(upc) == 12 {
+		variants = append(variants, "0"+upc)
+	}
+	return variants
+}
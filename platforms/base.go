@@ -1,29 +1,109 @@
 package platforms
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"net/http"
-	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"zoove/cache"
+	"zoove/types"
 	"zoove/util"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gomodule/redigo/redis"
 )
 
+// DefaultPlaylistTracksLimit is the max number of tracks fetched from a playlist when PLAYLIST_TRACKS_LIMIT isnt set
+const DefaultPlaylistTracksLimit = 1000
+
+// PlaylistTracksLimit returns the max number of tracks fetched from a playlist. Tracks after it are not converted
+func PlaylistTracksLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("PLAYLIST_TRACKS_LIMIT"))
+	if err != nil || limit < 1 {
+		return DefaultPlaylistTracksLimit
+	}
+	return limit
+}
+
+// PlaylistCacheID returns the id the playlist with the id on the platform is cached with
+func PlaylistCacheID(platform, id string) string {
+	return fmt.Sprintf("%s-%s", platform, id)
+}
+
 // TrackToSearch is a struct that represents a track to search on platforms
 type TrackToSearch struct {
 	Title   string
 	Artiste string
-	Pool    *redis.Pool
+	// ISRC is used to find the exact recording first. Title and Artiste are only used when there is no ISRC match
+	ISRC string
+	// Artistes, Album, Duration and Explicit are used to score the search results. see ScoreCandidate
+	Artistes []string
+	Album    string
+	Duration int
+	Explicit bool
+	Cache    *cache.Cache
 	// Chan    chan *types.SingleTrack
 }
 
 // TrackToSearchChan is a struct similar to TrackToSearch but async by using Chan
 
 // NewTrackToSearch returns a new instance of TrackToSearch
-func NewTrackToSearch(title, artiste string, pool *redis.Pool) *TrackToSearch {
-	return &TrackToSearch{Artiste: artiste, Title: title, Pool: pool}
+func NewTrackToSearch(title, artiste string, store *cache.Cache) *TrackToSearch {
+	return &TrackToSearch{Artiste: artiste, Title: title, Cache: store}
+}
+
+// NewTrackToSearchFromTrack returns a new instance of TrackToSearch for finding a track on other platforms
+func NewTrackToSearchFromTrack(track *types.SingleTrack, store *cache.Cache) *TrackToSearch {
+	artiste := ""
+	if len(track.Artistes) > 0 {
+		artiste = track.Artistes[0]
+	}
+	return &TrackToSearch{Artiste: artiste, Title: track.Title, ISRC: track.ISRC, Artistes: track.Artistes,
+		Album: track.Album, Duration: track.Duration, Explicit: track.Explicit, Cache: store}
+}
+
+// cacheKey returns the key of the search result on the platform. Everything the results are ranked by is part of it
+func (search *TrackToSearch) cacheKey(platform string) string {
+	hash := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s|%s|%s|%d|%t", search.ISRC, search.Title, search.Artiste,
+		strings.Join(search.Artistes, ","), search.Album, search.Duration, search.Explicit)))
+	return fmt.Sprintf("%s-%s", platform, hex.EncodeToString(hash[:]))
+}
+
+// AlbumToSearch is a struct that represents an album to search on platforms
+type AlbumToSearch struct {
+	Title   string
+	Artiste string
+	// UPC is used to find the exact release first. Title, Artiste and TracksNumber are only used when there is no UPC match
+	UPC          string
+	Artistes     []string
+	TracksNumber int
+	Cache        *cache.Cache
+}
+
+// NewAlbumToSearchFromAlbum returns a new instance of AlbumToSearch for finding an album on other platforms
+func NewAlbumToSearchFromAlbum(album *types.Album, store *cache.Cache) *AlbumToSearch {
+	artiste := ""
+	if len(album.Artistes) > 0 {
+		artiste = album.Artistes[0]
+	}
+	return &AlbumToSearch{Title: album.Title, Artiste: artiste, UPC: album.UPC, Artistes: album.Artistes,
+		TracksNumber: album.TracksNumber, Cache: store}
+}
+
+// ArtistToSearch is a struct that represents an artiste to search on platforms
+type ArtistToSearch struct {
+	Name string
+	// TopTracks are used to tell apart artistes with the same (or similar) name
+	TopTracks []types.SingleTrack
+	Cache     *cache.Cache
+}
+
+// NewArtistToSearchFromArtist returns a new instance of ArtistToSearch for finding an artiste on other platforms
+func NewArtistToSearchFromArtist(artist *types.Artist, store *cache.Cache) *ArtistToSearch {
+	return &ArtistToSearch{Name: artist.Name, TopTracks: artist.TopTracks, Cache: store}
 }
 
 // AuthorizeUser authorizes the user and returns the user profile
@@ -52,39 +132,22 @@ func AuthorizeUser(ctx *fiber.Ctx) {
 	// url := fmt.Sprintf("%s/oauth/auth.php?app_id=%s&redirect_uri=%s&perms=%s,%s,%s,%s,%s", os.Getenv("DEEZER_AUTH_BASE"), os.Getenv("DEEZER_APP_ID"), os.Getenv("DEEZER_REDIRECT_URI"), util.HostDeezerBasicAccessPermission, util.HostDeezerEmailPermission, util.HostDeezerOfflineAccessPermission, util.HostDeezerManageLibraryAccessPermission, util.HostDeezerListeningHistoryPermission)
 }
 
+// CreatePlaylistChan creates a playlist for a user on the platform and sends true to the channel if it was successful
 func CreatePlaylistChan(userID, title, token, platform string, tracks []string, ch chan bool) {
-
-	if platform == util.HostDeezer {
-		err := HostDeezerCreatePlaylist(url.QueryEscape(title), userID, token, tracks)
-		if err != nil {
-			log.Println("Error creating playlist")
-			log.Println(err)
-			ch <- false
-			return
-		}
-		ch <- true
+	target, ok := Get(platform)
+	if !ok {
+		ch <- false
 		return
-	} else if platform == util.HostSpotify {
-		spotifyTokens, err := HostSpotifyGetAuthorizedAcessToken(token)
-		if err != nil {
-			log.Println("Error getting correct access token for spotify")
-			log.Println(err)
-			ch <- false
-			return
-		}
+	}
 
-		err = HostSpotifyCreatePlaylist(userID, title, spotifyTokens.AccessToken, tracks)
-		if err != nil {
-			log.Println("Error creating spotify playlist")
-			log.Println(err)
-			ch <- false
-			return
-		}
-		ch <- true
+	err := target.CreatePlaylist(userID, title, token, tracks)
+	if err != nil {
+		log.Printf("Error creating %s playlist\n", platform)
+		log.Println(err)
+		ch <- false
 		return
 	}
-	ch <- false
-	return
+	ch <- true
 }
 
 // type PlaylistToSearch struct {
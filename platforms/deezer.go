@@ -11,14 +11,121 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"zoove/cache"
 	"zoove/errors"
+	"zoove/normalize"
 	"zoove/types"
 	"zoove/util"
-
-	"github.com/gomodule/redigo/redis"
-	"github.com/soveran/redisurl"
 )
 
+// deezerPlaylistPageSize is the number of playlist tracks fetched at a time
+const deezerPlaylistPageSize = 100
+
+// deezerNoData is the code of the error deezer responds with when what was requested doesnt exist
+const deezerNoData = 800
+
+// Deezer is the deezer platform
+type Deezer struct{}
+
+func init() {
+	Register(&Deezer{})
+}
+
+// Name returns the host name of deezer
+func (*Deezer) Name() string {
+	return util.HostDeezer
+}
+
+// GetSingleTrack returns a single (cached) deezer track
+func (*Deezer) GetSingleTrack(id string, store *cache.Cache) (*types.SingleTrack, error) {
+	return HostDeezerGetSingleTrack(id, store)
+}
+
+// SearchTrack searches deezer for a track
+func (*Deezer) SearchTrack(search *TrackToSearch) (*types.SingleTrack, error) {
+	return search.HostDeezerSearchTrack()
+}
+
+// GetAlbum returns a (cached) deezer album and its tracks
+func (*Deezer) GetAlbum(id string, store *cache.Cache) (*types.Album, error) {
+	return store.Album(fmt.Sprintf("%s-%s", util.HostDeezer, id), func() (*types.Album, error) {
+		return HostDeezerGetAlbum(id)
+	})
+}
+
+// SearchAlbum searches deezer for an album
+func (*Deezer) SearchAlbum(search *AlbumToSearch) (*types.Album, error) {
+	return search.HostDeezerSearchAlbum()
+}
+
+// GetArtist returns a (cached) deezer artiste and their top tracks
+func (*Deezer) GetArtist(id string, store *cache.Cache) (*types.Artist, error) {
+	return store.Artist(fmt.Sprintf("%s-%s", util.HostDeezer, id), func() (*types.Artist, error) {
+		return HostDeezerGetArtist(id)
+	})
+}
+
+// SearchArtist searches deezer for an artiste
+func (*Deezer) SearchArtist(search *ArtistToSearch) (*types.Artist, error) {
+	return search.HostDeezerSearchArtist()
+}
+
+// FetchPlaylistTracks returns a deezer playlist and its tracks
+func (*Deezer) FetchPlaylistTracks(id string, store *cache.Cache) (types.Playlist, error) {
+	return HostDeezerFetchPlaylistTracks(id, store)
+}
+
+// PlaylistSnapshot returns the checksum of a deezer playlist
+func (*Deezer) PlaylistSnapshot(id string) (string, error) {
+	return HostDeezerPlaylistSnapshot(id)
+}
+
+// CreatePlaylist creates a deezer playlist for a user
+func (*Deezer) CreatePlaylist(userID, title, token string, tracks []string) error {
+	return HostDeezerCreatePlaylist(url.QueryEscape(title), userID, token, tracks)
+}
+
+// UserAuth authorizes a deezer user and returns the user profile. The token returned is the deezer permanent access_token
+func (*Deezer) UserAuth(authcode string) (*types.NewUser, error) {
+	token, err := HostDeezerUserAuth(authcode)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := HostDeezerFetchUserProfile(token)
+	if err != nil {
+		log.Println("Error fetching user profile")
+		return nil, err
+	}
+
+	plan := ""
+	if profile.Status == 1 {
+		plan = "free"
+	} else if profile.Status == 2 {
+		plan = "premium"
+	}
+
+	return &types.NewUser{
+		ID:         profile.ID,
+		Email:      profile.Email,
+		FirstName:  profile.Firstname,
+		LastName:   profile.Lastname,
+		Country:    profile.Country,
+		Lang:       profile.Lang,
+		Username:   profile.Name,
+		Avatar:     profile.Picture,
+		Platform:   util.HostDeezer,
+		Token:      token,
+		Plan:       plan,
+		PlatformID: strconv.Itoa(profile.ID),
+	}, nil
+}
+
+// FetchHistory returns the tracks a deezer user recently played
+func (*Deezer) FetchHistory(token string) ([]types.SingleTrack, error) {
+	return HostDeezerFetchHistory(token)
+}
+
 // HostDeezerUserAuth authorizes the user and returns the deezer permanent access_token
 func HostDeezerUserAuth(authcode string) (string, error) {
 	type deezerToken struct {
@@ -37,51 +144,60 @@ func HostDeezerUserAuth(authcode string) (string, error) {
 	return tok.AccessToken, nil
 }
 
-// HostDeezerExtractTitle exteacts and returns the title. It removes (feat <bla bla>) from the title. This is because this title is used to search spotify
+// HostDeezerExtractTitle exteacts and returns the title. It removes (feat <bla bla>) and the version (remastered, live etc) from the title. This is because this title is used to search
 func HostDeezerExtractTitle(title string) string {
-	// we want to check for the first occurence of "Feat"
-	ind := strings.Index(title, "(feat")
-	if ind == -1 {
-		return title
-	}
-	out := title[:ind]
-	return out
+	return normalize.SearchTitle(title)
 }
 
 // HostDeezerSearchTrackChan searches deezer for a track and returns a single track but using channels
 func (search *TrackToSearch) HostDeezerSearchTrackChan(ch chan *types.SingleTrack) {
-	conn := search.Pool.Get()
-	defer conn.Close()
+	track, err := search.HostDeezerSearchTrack()
+	if err != nil {
+		ch <- nil
+		return
+	}
+	ch <- track
+}
+
+// HostDeezerSearchTrack searches deezer for a track and returns a single track. It looks up the track by ISRC first and
+// only falls back to searching with the title and artiste when there is no ISRC match. The result is cached.
+func (search *TrackToSearch) HostDeezerSearchTrack() (*types.SingleTrack, error) {
+	return search.Cache.Track(cache.Search, search.cacheKey(util.HostDeezer), search.hostDeezerSearchTrack)
+}
+
+func (search *TrackToSearch) hostDeezerSearchTrack() (*types.SingleTrack, error) {
+	// the track is on deezer but cant be played here. we still search in case there's another (playable) release of it
+	blocked := false
+	if search.ISRC != "" {
+		track, err := HostDeezerGetTrackByISRC(search.ISRC)
+		if err == nil {
+			track.MatchStrategy = util.MatchStrategyISRC
+			track.Confidence = 1
+			return track, nil
+		}
+		blocked = err == errors.RegionBlocked
+		log.Printf("Could not find track with ISRC %s on deezer. Searching instead\n", search.ISRC)
+	}
 
 	title := HostDeezerExtractTitle(search.Title)
 	payload := url.QueryEscape(fmt.Sprintf("track:\"%s\" artist:\"%s\"", title, search.Artiste))
-	url := fmt.Sprintf("%s/search?q=%s", os.Getenv("DEEZER_API_BASE"), payload)
+	url := fmt.Sprintf("%s/search?q=%s&limit=%d", os.Getenv("DEEZER_API_BASE"), payload, SearchCandidatesLimit)
 	output := &types.HostDeezerSearchTrack{}
 	err := MakeDeezerRequest(url, output)
 	if err != nil {
 		log.Println("Error searching on deezer for track")
 		log.Println(err)
+		return nil, err
 	}
 
-	// log.Printf("Output from deezer search%#v", output)
-	if len(output.Data) > 0 {
-		base := output.Data[0]
-		key := fmt.Sprintf("%s-%s", util.HostDeezer, strconv.Itoa(base.ID))
-
-		if err != nil {
-			log.Println("Error getting from redis")
-			log.Println(err)
-			if err == redis.ErrNil {
-				log.Println("The track has not been previously cached.")
-				_, err := redis.String(conn.Do("SET", key, output))
-				if err != nil {
-					log.Println("Error inserting track into DB")
-				}
-			}
+	candidates := []types.SingleTrack{}
+	for _, base := range output.Data {
+		if !base.Readable {
+			blocked = true
+			continue
 		}
-
 		id := strconv.Itoa(base.ID)
-		track := &types.SingleTrack{
+		candidates = append(candidates, types.SingleTrack{
 			Cover:       base.Album.Cover,
 			Artistes:    []string{base.Artist.Name},
 			Duration:    base.Duration * 1000,
@@ -93,163 +209,217 @@ func (search *TrackToSearch) HostDeezerSearchTrackChan(ch chan *types.SingleTrac
 			URL:         base.Link,
 			ReleaseDate: "",
 			Album:       base.Album.Title,
-		}
+		})
+	}
 
-		// return track, nil
-		ch <- track
-		// log.Printf("Track title for song under playlist is: %s\n\n", track.Title)
-		return
+	if len(candidates) == 0 && blocked {
+		return nil, errors.RegionBlocked
 	}
-	// log.Printf("Deezer error: %#v\n\n", err)
-	// return nil, errors.NotFound
-	ch <- nil
+	track, err := search.RankCandidates(candidates)
+	if err != nil {
+		return nil, err
+	}
+	track.MatchStrategy = util.MatchStrategySearch
+	return track, nil
 }
 
-// HostDeezerSearchTrack searches deezer for a track and returns a single track
-func (search *TrackToSearch) HostDeezerSearchTrack() (*types.SingleTrack, error) {
-	conn := search.Pool.Get()
-	defer conn.Close()
-
-	title := HostDeezerExtractTitle(search.Title)
-	payload := url.QueryEscape(fmt.Sprintf("track:\"%s\" artist:\"%s\"", title, search.Artiste))
-	url := fmt.Sprintf("%s/search?q=%s", os.Getenv("DEEZER_API_BASE"), payload)
-	output := &types.HostDeezerSearchTrack{}
-	err := MakeDeezerRequest(url, output)
+// HostDeezerGetTrackByISRC returns the deezer track with the ISRC. It returns errors.RegionBlocked if the track cant be played
+func HostDeezerGetTrackByISRC(isrc string) (*types.SingleTrack, error) {
+	url := fmt.Sprintf("%s/track/isrc:%s", os.Getenv("DEEZER_API_BASE"), isrc)
+	dz := &types.HostDeezerTrack{}
+	err := MakeDeezerRequest(url, dz)
 	if err != nil {
-		log.Println("Error searching on deezer for track")
-		log.Println(err)
+		return nil, err
+	}
+	if dz.ID == 0 {
+		return nil, errors.NotFound
 	}
+	if !dz.Readable {
+		return nil, errors.RegionBlocked
+	}
+	return hostDeezerTrackToSingleTrack(dz), nil
+}
 
-	// log.Printf("Output from deezer search%#v", output)
-	if len(output.Data) > 0 {
-		base := output.Data[0]
-		key := fmt.Sprintf("%s-%s", util.HostDeezer, strconv.Itoa(base.ID))
-		log.Println("Result to search ", base)
-		if err != nil {
-			log.Println("Error getting from redis")
-			log.Println(err)
-			if err == redis.ErrNil {
-				log.Println("The track has not been previously cached.")
-				_, err := redis.String(conn.Do("SET", key, output))
-				if err != nil {
-					log.Println("Error inserting track into DB")
-				}
-			}
-		}
+// HostDeezerGetAlbum returns a deezer album and its tracks
+func HostDeezerGetAlbum(albumID string) (*types.Album, error) {
+	return hostDeezerFetchAlbum(fmt.Sprintf("%s/album/%s", os.Getenv("DEEZER_API_BASE"), albumID))
+}
 
-		id := strconv.Itoa(base.ID)
-		log.Println("Single ")
-		track := &types.SingleTrack{
-			Cover:       base.Album.Cover,
-			Artistes:    []string{base.Artist.Name},
-			Duration:    base.Duration * 1000,
-			Explicit:    base.ExplicitLyrics,
-			ID:          id,
-			Platform:    util.HostDeezer,
-			Preview:     base.Preview,
-			Title:       base.Title,
-			URL:         base.Link,
-			ReleaseDate: "",
-			Album:       base.Album.Title,
-		}
+// HostDeezerGetAlbumByUPC returns the deezer album with the UPC
+func HostDeezerGetAlbumByUPC(upc string) (*types.Album, error) {
+	return hostDeezerFetchAlbum(fmt.Sprintf("%s/album/upc:%s", os.Getenv("DEEZER_API_BASE"), upc))
+}
 
-		return track, nil
+// hostDeezerFetchAlbum fetches a deezer album (and its tracks) from the url
+func hostDeezerFetchAlbum(url string) (*types.Album, error) {
+	dz := &types.HostDeezerAlbum{}
+	err := MakeDeezerRequest(url, dz)
+	if err != nil {
+		return nil, err
+	}
+	if dz.ID == 0 {
+		return nil, errors.NotFound
 	}
 
-	return nil, errors.NotFound
+	album := &types.Album{Title: dz.Title, URL: dz.Link, Cover: dz.Cover, ReleaseDate: dz.ReleaseDate, UPC: dz.Upc,
+		TracksNumber: dz.NbTracks, Duration: dz.Duration * 1000, Explicit: dz.ExplicitLyrics, Platform: util.HostDeezer,
+		ID: strconv.Itoa(dz.ID), Tracks: []types.SingleTrack{}}
+	for _, contributor := range dz.Contributors {
+		album.Artistes = append(album.Artistes, contributor.Name)
+	}
+	if len(album.Artistes) == 0 && dz.Artist.Name != "" {
+		album.Artistes = []string{dz.Artist.Name}
+	}
+	for _, track := range dz.Tracks.Data {
+		album.Tracks = append(album.Tracks, types.SingleTrack{Cover: dz.Cover, Artistes: []string{track.Artist.Name},
+			Duration: track.Duration * 1000, Explicit: track.ExplicitLyrics, ID: strconv.Itoa(track.ID), Platform: util.HostDeezer,
+			Preview: track.Preview, ReleaseDate: dz.ReleaseDate, Title: track.Title, URL: track.Link, Album: dz.Title})
+	}
+	return album, nil
 }
 
-// HostDeezerGetSingleTrackChan returns a single deezer track (DOING THE CACHING) but using a go routine
-func HostDeezerGetSingleTrackChan(deezerID string, pool *redis.Pool, ch chan *types.SingleTrack) {
-	conn := pool.Get()
-	defer conn.Close()
+// HostDeezerSearchAlbum searches deezer for an album and returns it with its tracks. It looks up the album by UPC first
+// and only falls back to searching with the title and artiste when there is no UPC match.
+func (search *AlbumToSearch) HostDeezerSearchAlbum() (*types.Album, error) {
+	for _, upc := range UPCVariants(search.UPC) {
+		album, err := HostDeezerGetAlbumByUPC(upc)
+		if err == nil {
+			album.MatchStrategy = util.MatchStrategyUPC
+			album.Confidence = 1
+			return album, nil
+		}
+	}
+	if search.UPC != "" {
+		log.Printf("Could not find album with UPC %s on deezer. Searching instead\n", search.UPC)
+	}
 
-	key := fmt.Sprintf("%s-%s", util.HostDeezer, deezerID)
-	values, err := redis.String(conn.Do("GET", key))
+	title := HostDeezerExtractTitle(search.Title)
+	payload := url.QueryEscape(fmt.Sprintf("album:\"%s\" artist:\"%s\"", title, search.Artiste))
+	url := fmt.Sprintf("%s/search/album?q=%s&limit=%d", os.Getenv("DEEZER_API_BASE"), payload, SearchCandidatesLimit)
+	output := &types.HostDeezerSearchAlbum{}
+	err := MakeDeezerRequest(url, output)
 	if err != nil {
-		// log.Println("Error getting from cache")
+		log.Println("Error searching on deezer for album")
 		log.Println(err)
-		if err == redis.ErrNil {
-			url := fmt.Sprintf("%s/track/%s", os.Getenv("DEEZER_API_BASE"), deezerID)
-			dz := &types.HostDeezerTrack{}
-			err = MakeDeezerRequest(url, dz)
-			id := strconv.Itoa(dz.ID)
-			single := &types.SingleTrack{Cover: dz.Album.Cover, Duration: dz.Duration * 1000, Explicit: dz.ExplicitLyrics, Platform: util.HostDeezer, Preview: dz.Preview, ReleaseDate: dz.ReleaseDate, Title: dz.Title, URL: dz.Link, ID: id}
-			for _, elem := range dz.Contributors {
-				single.Artistes = append(single.Artistes, elem.Name)
-			}
-
-			serialized, err := json.Marshal(single)
-			if err != nil {
-				log.Println("Error unserializing")
-				log.Println(err)
-			}
-
-			_, err = redis.String(conn.Do("SET", key, string(serialized)))
-			if err != nil {
-				log.Println("Error saving into redis.")
-				log.Println(err)
-			}
-			// return single, nil
-		}
+		return nil, err
 	}
 
-	single := &types.SingleTrack{}
+	candidates := []types.Album{}
+	for _, base := range output.Data {
+		candidates = append(candidates, types.Album{Title: base.Title, Artistes: []string{base.Artist.Name}, URL: base.Link,
+			Cover: base.Cover, TracksNumber: base.NbTracks, Explicit: base.ExplicitLyrics, Platform: util.HostDeezer,
+			ID: strconv.Itoa(base.ID)})
+	}
 
-	err = json.Unmarshal([]byte(values), single)
+	best, err := search.RankAlbums(candidates)
 	if err != nil {
-		log.Println("Error serializing the result from redis into a response")
-		// return nil, err
-		ch <- nil
+		return nil, err
+	}
+	// search results dont have the tracks of the album
+	album, err := HostDeezerGetAlbum(best.ID)
+	if err != nil {
+		return nil, err
 	}
-	ch <- single
-	// return single, err
+	album.MatchStrategy = util.MatchStrategySearch
+	album.Confidence = best.Confidence
+	return album, nil
 }
 
-// HostDeezerGetSingleTrack returns a single deezer track (DOING THE CACHING)
-func HostDeezerGetSingleTrack(deezerID string, pool *redis.Pool) (*types.SingleTrack, error) {
-	conn := pool.Get()
-	defer conn.Close()
+// HostDeezerGetArtist returns a deezer artiste and their top tracks
+func HostDeezerGetArtist(artistID string) (*types.Artist, error) {
+	deezerAPIBase := os.Getenv("DEEZER_API_BASE")
+	dz := &types.HostDeezerArtist{}
+	err := MakeDeezerRequest(fmt.Sprintf("%s/artist/%s", deezerAPIBase, artistID), dz)
+	if err != nil {
+		return nil, err
+	}
+	if dz.ID == 0 {
+		return nil, errors.NotFound
+	}
+
+	top := &types.HostDeezerArtistTopTracks{}
+	err = MakeDeezerRequest(fmt.Sprintf("%s/artist/%s/top?limit=%d", deezerAPIBase, artistID, TopTracksLimit), top)
+	if err != nil {
+		log.Println("Error fetching deezer artiste top tracks")
+		return nil, err
+	}
+
+	artist := hostDeezerArtistToArtist(dz)
+	artist.TopTracks = []types.SingleTrack{}
+	for index := range top.Data {
+		track := hostDeezerTrackToSingleTrack(&top.Data[index])
+		// top tracks only have the contributors
+		if len(track.Artistes) == 0 {
+			track.Artistes = []string{top.Data[index].Artist.Name}
+		}
+		artist.TopTracks = append(artist.TopTracks, *track)
+	}
+	return artist, nil
+}
 
-	key := fmt.Sprintf("%s-%s", util.HostDeezer, deezerID)
-	values, err := redis.String(conn.Do("GET", key))
+// HostDeezerSearchArtist searches deezer for an artiste and returns them with their top tracks. The artistes found are
+// told apart using their top tracks
+func (search *ArtistToSearch) HostDeezerSearchArtist() (*types.Artist, error) {
+	payload := url.QueryEscape(fmt.Sprintf("artist:\"%s\"", search.Name))
+	url := fmt.Sprintf("%s/search/artist?q=%s&limit=%d", os.Getenv("DEEZER_API_BASE"), payload, ArtistCandidatesLimit)
+	output := &types.HostDeezerSearchArtist{}
+	err := MakeDeezerRequest(url, output)
 	if err != nil {
-		// log.Println("Error getting from cache")
+		log.Println("Error searching on deezer for artiste")
 		log.Println(err)
-		if err == redis.ErrNil {
-
-			url := fmt.Sprintf("%s/track/%s", os.Getenv("DEEZER_API_BASE"), deezerID)
-			dz := &types.HostDeezerTrack{}
-			err = MakeDeezerRequest(url, dz)
-			id := strconv.Itoa(dz.ID)
-			single := &types.SingleTrack{Cover: dz.Album.Cover, Duration: dz.Duration * 1000, Explicit: dz.ExplicitLyrics, Platform: util.HostDeezer, Preview: dz.Preview, ReleaseDate: dz.ReleaseDate, Title: dz.Title, URL: dz.Link, ID: id}
-			for _, elem := range dz.Contributors {
-				single.Artistes = append(single.Artistes, elem.Name)
-			}
-
-			serialized, err := json.Marshal(single)
-			if err != nil {
-				log.Println("Error unserializing")
-				log.Println(err)
-			}
-
-			_, err = redis.String(conn.Do("SET", key, string(serialized)))
-			if err != nil {
-				log.Println("Error saving into redis.")
-				log.Println(err)
-			}
-			return single, nil
+		return nil, err
+	}
+
+	candidates := []types.Artist{}
+	for _, base := range output.Data {
+		artist, err := HostDeezerGetArtist(strconv.Itoa(base.ID))
+		if err != nil {
+			log.Printf("Error fetching deezer artiste %d. Skipping\n", base.ID)
+			continue
 		}
+		candidates = append(candidates, *artist)
 	}
+	return search.RankArtists(candidates)
+}
 
-	single := &types.SingleTrack{}
+// hostDeezerArtistToArtist returns the Artist of a deezer artiste (without the top tracks)
+func hostDeezerArtistToArtist(dz *types.HostDeezerArtist) *types.Artist {
+	return &types.Artist{Name: dz.Name, URL: dz.Link, Picture: dz.PictureXl, Fans: dz.NbFan, Albums: dz.NbAlbum,
+		Platform: util.HostDeezer, ID: strconv.Itoa(dz.ID)}
+}
 
-	err = json.Unmarshal([]byte(values), single)
+// hostDeezerTrackToSingleTrack returns the SingleTrack of a deezer track
+func hostDeezerTrackToSingleTrack(dz *types.HostDeezerTrack) *types.SingleTrack {
+	id := strconv.Itoa(dz.ID)
+	single := &types.SingleTrack{Cover: dz.Album.Cover, Duration: dz.Duration * 1000, Explicit: dz.ExplicitLyrics, Platform: util.HostDeezer,
+		Preview: dz.Preview, ReleaseDate: dz.ReleaseDate, Title: dz.Title, URL: dz.Link, ID: id, Album: dz.Album.Title, ISRC: dz.Isrc}
+	for _, elem := range dz.Contributors {
+		single.Artistes = append(single.Artistes, elem.Name)
+	}
+	return single
+}
+
+// HostDeezerGetSingleTrackChan returns a single (cached) deezer track but using a channel
+func HostDeezerGetSingleTrackChan(deezerID string, store *cache.Cache, ch chan *types.SingleTrack) {
+	track, err := HostDeezerGetSingleTrack(deezerID, store)
 	if err != nil {
-		log.Println("Error serializing the result from redis into a response")
-		return nil, err
+		ch <- nil
+		return
 	}
-	return single, err
+	ch <- track
+}
+
+// HostDeezerGetSingleTrack returns a single (cached) deezer track
+func HostDeezerGetSingleTrack(deezerID string, store *cache.Cache) (*types.SingleTrack, error) {
+	return store.Track(cache.Track, fmt.Sprintf("%s-%s", util.HostDeezer, deezerID), func() (*types.SingleTrack, error) {
+		url := fmt.Sprintf("%s/track/%s", os.Getenv("DEEZER_API_BASE"), deezerID)
+		dz := &types.HostDeezerTrack{}
+		err := MakeDeezerRequest(url, dz)
+		if err != nil {
+			return nil, err
+		}
+		return hostDeezerTrackToSingleTrack(dz), nil
+	})
 }
 
 // MakeDeezerRequest makes an http request to deezer
@@ -271,13 +441,13 @@ func MakeDeezerRequest(url string, out interface{}) error {
 	body, err := ioutil.ReadAll(res.Body)
 	defer res.Body.Close()
 	// log.Printf("Body of response: %s", string(body))
-	if strings.Contains(string(body), "{\"error\"") {
-		return errors.NotFound
-	}
 	if err != nil {
 		log.Println("Error reading response body into memory")
 		return err
 	}
+	if strings.Contains(string(body), "{\"error\"") {
+		return deezerError(body)
+	}
 	if res.StatusCode == http.StatusUnauthorized {
 		return errors.UnAuthorized
 	}
@@ -295,6 +465,23 @@ func MakeDeezerRequest(url string, out interface{}) error {
 	return nil
 }
 
+// deezerError returns the error in a deezer error response. Only "no data" (code 800) means what was requested doesnt
+// exist. The others (e.g the quota, code 4) are errors of the request, so they arent cached as not found.
+func deezerError(body []byte) error {
+	response := &types.HostDeezerError{}
+	err := json.Unmarshal(body, response)
+	if err != nil {
+		log.Println("Error unserializing the deezer error")
+		log.Println(err)
+		return err
+	}
+	if response.Error.Code == deezerNoData {
+		return errors.NotFound
+	}
+	log.Printf("Deezer responded with error %d: %s\n", response.Error.Code, response.Error.Message)
+	return fmt.Errorf("deezer error %d (%s): %s", response.Error.Code, response.Error.Type, response.Error.Message)
+}
+
 // HostDeezerFetchUserProfile returns a user's profile and an error if any.
 func HostDeezerFetchUserProfile(token string) (*types.HostDeezerRawUserProfile, error) {
 	url := fmt.Sprintf("%s/user/me?access_token=%s", os.Getenv("DEEZER_API_BASE"), token)
@@ -356,17 +543,25 @@ func HostDeezerFetchArtisteHistory(token string) ([]string, error) {
 	return history, nil
 }
 
-// HostDeezerFetchPlaylistTracks returns the deezer playlist information
-func HostDeezerFetchPlaylistTracks(playlistID string, pool *redis.Pool) (types.Playlist, error) {
-	pool = &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			// log.Println(os.Getenv("REDIS_URL"))
-			return redisurl.Connect()
-		},
+// HostDeezerFetchPlaylistTracks returns the (cached) deezer playlist information
+func HostDeezerFetchPlaylistTracks(playlistID string, store *cache.Cache) (types.Playlist, error) {
+	return store.Playlist(PlaylistCacheID(util.HostDeezer, playlistID), func() (types.Playlist, error) {
+		return hostDeezerFetchPlaylistTracks(playlistID)
+	})
+}
+
+// HostDeezerPlaylistSnapshot returns the current checksum of a deezer playlist. It changes whenever its tracks do
+func HostDeezerPlaylistSnapshot(playlistID string) (string, error) {
+	deezerPlaylist := &types.HostDeezerPlaylistResponse{}
+	url := fmt.Sprintf("%s/playlist/%s", os.Getenv("DEEZER_API_BASE"), playlistID)
+	err := MakeDeezerRequest(url, deezerPlaylist)
+	if err != nil {
+		return "", err
 	}
-	conn := pool.Get()
-	defer conn.Close()
+	return deezerPlaylist.Checksum, nil
+}
 
+func hostDeezerFetchPlaylistTracks(playlistID string) (types.Playlist, error) {
 	deezerPlaylist := &types.HostDeezerPlaylistResponse{}
 
 	deezerBaseAPI := os.Getenv("DEEZER_API_BASE")
@@ -381,27 +576,57 @@ func HostDeezerFetchPlaylistTracks(playlistID string, pool *redis.Pool) (types.P
 	playlist := &types.Playlist{Description: deezerPlaylist.Description, Collaborative: deezerPlaylist.Collaborative,
 		Duration: deezerPlaylist.Duration, TracksNumber: deezerPlaylist.NbTracks, Title: deezerPlaylist.Title,
 		Tracks: []types.SingleTrack{}, Owner: types.PlaylistOwner{Avatar: deezerPlaylist.Picture, ID: id, Name: deezerPlaylist.Creator.Name},
-		URL: deezerPlaylist.Link, Cover: deezerPlaylist.Picture,
+		URL: deezerPlaylist.Link, Cover: deezerPlaylist.Picture, Snapshot: deezerPlaylist.Checksum,
 	}
-	for _, track := range deezerPlaylist.Tracks.Data {
+	tracks, err := hostDeezerFetchRemainingPlaylistTracks(playlistID, deezerPlaylist)
+	if err != nil {
+		return types.Playlist{}, err
+	}
+	for _, track := range tracks {
 		dur := strconv.Itoa(track.Duration)
 		trackDuration, _ := strconv.ParseInt(dur, 10, 64)
 		timeAdded := time.Unix(int64(track.TimeAdd), 0)
 		tID := strconv.Itoa(track.ID)
 		single := &types.SingleTrack{Cover: track.Album.Cover, Artistes: []string{track.Artist.Name}, Duration: int(trackDuration) * 1000, Explicit: track.ExplicitLyrics,
 			ID: tID, Platform: util.HostDeezer, Preview: track.Preview, Title: track.Title, AddedAt: timeAdded.String(),
-			URL: track.Link,
+			URL: track.Link, Album: track.Album.Title,
 		}
 		playlist.Tracks = append(playlist.Tracks, *single)
 	}
-
-	if err != nil {
-		return types.Playlist{}, err
-	}
+	playlist.FetchedTracks = len(playlist.Tracks)
 	// log.Printf("Playlist is: %#v", playlist)
 	return *playlist, nil
 }
 
+// hostDeezerFetchRemainingPlaylistTracks returns all the tracks of a deezer playlist (at most PlaylistTracksLimit). The
+// playlist only has the first tracks, the rest are fetched from the playlist's tracklist page by page.
+func hostDeezerFetchRemainingPlaylistTracks(playlistID string, deezerPlaylist *types.HostDeezerPlaylistResponse) ([]types.HostDeezerPlaylistTrack, error) {
+	limit := PlaylistTracksLimit()
+	tracks := deezerPlaylist.Tracks.Data
+	next := ""
+	if len(tracks) < deezerPlaylist.NbTracks {
+		next = fmt.Sprintf("%s/playlist/%s/tracks?index=%d&limit=%d", os.Getenv("DEEZER_API_BASE"), playlistID, len(tracks), deezerPlaylistPageSize)
+	}
+	for next != "" && len(tracks) < limit {
+		page := &types.HostDeezerPlaylistTracks{}
+		err := MakeDeezerRequest(next, page)
+		if err != nil {
+			log.Println("Error fetching the next page of deezer playlist tracks")
+			log.Println(err)
+			return nil, err
+		}
+		if len(page.Data) == 0 {
+			break
+		}
+		tracks = append(tracks, page.Data...)
+		next = page.Next
+	}
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks, nil
+}
+
 // HostDeezerCreatePlaylist creates a new playlist for the deezer user
 func HostDeezerCreatePlaylist(title, userid, token string, tracks []string) error {
 	deezerAPIBase := os.Getenv("DEEZER_API_BASE")
@@ -0,0 +1,17 @@
+package platforms
+
+import (
+	"testing"
+	"zoove/errors"
+)
+
+func TestDeezerError(t *testing.T) {
+	err := deezerError([]byte(`{"error":{"type":"DataException","message":"no data","code":800}}`))
+	if err != errors.NotFound {
+		t.Errorf("expected no data to be not found, got %v", err)
+	}
+	err = deezerError([]byte(`{"error":{"type":"Exception","message":"Quota limit exceeded","code":4}}`))
+	if err == nil || err == errors.NotFound {
+		t.Errorf("expected the quota error not to be not found, got %v", err)
+	}
+}
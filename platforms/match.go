@@ -0,0 +1,302 @@
+package platforms
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"zoove/errors"
+	"zoove/normalize"
+	"zoove/types"
+)
+
+const (
+	// ArtistCandidatesLimit is the number of artistes found by a search that we fetch the top tracks of (and score)
+	ArtistCandidatesLimit = 3
+	// TopTracksLimit is the number of top tracks of an artiste we fetch
+	TopTracksLimit = 10
+	// SearchCandidatesLimit is the number of search results we fetch (and score) when searching for a track
+	SearchCandidatesLimit = 5
+	// MaxAlternatives is the max number of runner up search results returned with a match
+	MaxAlternatives = 3
+	// MinConfidence is the min confidence of the best search result for it to be returned. Below it, the results are
+	// too different from the track searched for and it is not found.
+	MinConfidence = 0.5
+	// MinAlbumConfidence is the min confidence of the best album search result for it to be returned. e.g an album with
+	// the same title by other artistes is not the album searched for.
+	MinAlbumConfidence = 0.75
+	// MinArtistConfidence is the min confidence of the best artiste search result for it to be returned. An artiste with
+	// the same name but none of the same top tracks scores 0.5 and is someone else.
+	MinArtistConfidence = 0.6
+)
+
+// how much each of the things we compare counts in the score of a search result
+const (
+	titleWeight    = 0.40
+	artisteWeight  = 0.25
+	albumWeight    = 0.10
+	durationWeight = 0.20
+	explicitWeight = 0.05
+)
+
+// how much each of the things we compare counts in the score of an album search result
+const (
+	albumTitleWeight   = 0.50
+	albumArtisteWeight = 0.30
+	albumTracksWeight  = 0.20
+)
+
+// how much each of the things we compare counts in the score of an artiste search result
+const (
+	artistNameWeight      = 0.50
+	artistTopTracksWeight = 0.50
+)
+
+// a duration difference within durationTolerance (ms) is a perfect match. At durationCutoff and above, it doesnt match at all
+const (
+	durationTolerance = 2000
+	durationCutoff    = 30000
+)
+
+// RankCandidates scores the search results and returns the best one with its confidence and the runner ups as alternatives.
+// It returns errors.NotFound when there are none or the best one is below MinConfidence.
+func (search *TrackToSearch) RankCandidates(candidates []types.SingleTrack) (*types.SingleTrack, error) {
+	if len(candidates) == 0 {
+		return nil, errors.NotFound
+	}
+
+	ranked := make([]types.SingleTrack, len(candidates))
+	copy(ranked, candidates)
+	for index := range ranked {
+		ranked[index].Confidence = search.ScoreCandidate(&ranked[index])
+	}
+	// stable so that the platform's own ordering breaks ties
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Confidence > ranked[j].Confidence
+	})
+
+	best := ranked[0]
+	if best.Confidence < MinConfidence {
+		return nil, errors.NotFound
+	}
+	alternatives := ranked[1:]
+	if len(alternatives) > MaxAlternatives {
+		alternatives = alternatives[:MaxAlternatives]
+	}
+	best.Alternatives = append([]types.SingleTrack{}, alternatives...)
+	return &best, nil
+}
+
+// ScoreCandidate returns how confident (0-1) we are that the candidate is the track searched for. It compares the title,
+// artistes, album, duration and explicit flag. Things we dont know for either of the tracks (e.g deezer search results dont
+// have all the artistes) are left out of the score instead of counting against it.
+func (search *TrackToSearch) ScoreCandidate(candidate *types.SingleTrack) float64 {
+	score, total := 0.0, 0.0
+
+	score += titleWeight * similarity(normalize.Title(search.Title), normalize.Title(candidate.Title))
+	total += titleWeight
+
+	artistes := search.Artistes
+	if len(artistes) == 0 && search.Artiste != "" {
+		artistes = []string{search.Artiste}
+	}
+	searchArtistes, candidateArtistes := splitArtistes(artistes), splitArtistes(candidate.Artistes)
+	if len(searchArtistes) > 0 && len(candidateArtistes) > 0 {
+		score += artisteWeight * artisteOverlap(searchArtistes, candidateArtistes)
+		total += artisteWeight
+	}
+
+	if search.Album != "" && candidate.Album != "" {
+		score += albumWeight * similarity(normalize.Title(search.Album), normalize.Title(candidate.Album))
+		total += albumWeight
+	}
+
+	if search.Duration > 0 && candidate.Duration > 0 {
+		score += durationWeight * durationCloseness(search.Duration, candidate.Duration)
+		total += durationWeight
+	}
+
+	if search.Explicit == candidate.Explicit {
+		score += explicitWeight
+	}
+	total += explicitWeight
+
+	return math.Round(score/total*100) / 100
+}
+
+// RankAlbums scores the album search results and returns the best one with its confidence. It returns errors.NotFound
+// when there are none or the best one is below MinAlbumConfidence.
+func (search *AlbumToSearch) RankAlbums(candidates []types.Album) (*types.Album, error) {
+	if len(candidates) == 0 {
+		return nil, errors.NotFound
+	}
+
+	var best *types.Album
+	for index := range candidates {
+		candidates[index].Confidence = search.ScoreAlbum(&candidates[index])
+		// the platform's own ordering breaks ties
+		if best == nil || candidates[index].Confidence > best.Confidence {
+			best = &candidates[index]
+		}
+	}
+	if best.Confidence < MinAlbumConfidence {
+		return nil, errors.NotFound
+	}
+	return best, nil
+}
+
+// ScoreAlbum returns how confident (0-1) we are that the candidate is the album searched for. It compares the title,
+// artistes and the number of tracks. Like ScoreCandidate, things we dont know are left out of the score.
+func (search *AlbumToSearch) ScoreAlbum(candidate *types.Album) float64 {
+	score, total := 0.0, 0.0
+
+	score += albumTitleWeight * similarity(normalize.Title(search.Title), normalize.Title(candidate.Title))
+	total += albumTitleWeight
+
+	artistes := search.Artistes
+	if len(artistes) == 0 && search.Artiste != "" {
+		artistes = []string{search.Artiste}
+	}
+	searchArtistes, candidateArtistes := splitArtistes(artistes), splitArtistes(candidate.Artistes)
+	if len(searchArtistes) > 0 && len(candidateArtistes) > 0 {
+		score += albumArtisteWeight * artisteOverlap(searchArtistes, candidateArtistes)
+		total += albumArtisteWeight
+	}
+
+	if search.TracksNumber > 0 && candidate.TracksNumber > 0 {
+		score += albumTracksWeight * tracksCloseness(search.TracksNumber, candidate.TracksNumber)
+		total += albumTracksWeight
+	}
+
+	return math.Round(score/total*100) / 100
+}
+
+// RankArtists scores the artiste search results and returns the best one with its confidence. It returns
+// errors.NotFound when there are none or the best one is below MinArtistConfidence.
+func (search *ArtistToSearch) RankArtists(candidates []types.Artist) (*types.Artist, error) {
+	if len(candidates) == 0 {
+		return nil, errors.NotFound
+	}
+
+	var best *types.Artist
+	for index := range candidates {
+		candidates[index].Confidence = search.ScoreArtist(&candidates[index])
+		// the platform's own ordering breaks ties
+		if best == nil || candidates[index].Confidence > best.Confidence {
+			best = &candidates[index]
+		}
+	}
+	if best.Confidence < MinArtistConfidence {
+		return nil, errors.NotFound
+	}
+	return best, nil
+}
+
+// ScoreArtist returns how confident (0-1) we are that the candidate is the artiste searched for. It compares the names and
+// the top tracks since different artistes often have the same name.
+func (search *ArtistToSearch) ScoreArtist(candidate *types.Artist) float64 {
+	score, total := 0.0, 0.0
+
+	score += artistNameWeight * similarity(normalize.Artiste(search.Name), normalize.Artiste(candidate.Name))
+	total += artistNameWeight
+
+	if len(search.TopTracks) > 0 && len(candidate.TopTracks) > 0 {
+		score += artistTopTracksWeight * topTracksOverlap(search.TopTracks, candidate.TopTracks)
+		total += artistTopTracksWeight
+	}
+
+	return math.Round(score/total*100) / 100
+}
+
+// similarity returns how similar (0-1) two normalized titles (or names) are, using the words they share
+func similarity(a, b string) float64 {
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	if strings.Join(wordsA, " ") == strings.Join(wordsB, " ") {
+		return 1
+	}
+
+	set := map[string]bool{}
+	for _, word := range wordsA {
+		set[word] = true
+	}
+	shared, union := 0, len(set)
+	seen := map[string]bool{}
+	for _, word := range wordsB {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		if set[word] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
+
+// artisteOverlap returns how much (0-1) two lists of artistes overlap. It is measured against the shorter list since
+// some platforms only return the main artiste.
+func artisteOverlap(a, b []string) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	total := 0.0
+	for _, artiste := range a {
+		best := 0.0
+		for _, other := range b {
+			best = math.Max(best, similarity(artiste, other))
+		}
+		total += best
+	}
+	return total / float64(len(a))
+}
+
+// durationCloseness returns how close (0-1) two durations (in ms) are
+func durationCloseness(a, b int) float64 {
+	delta := math.Abs(float64(a - b))
+	if delta <= durationTolerance {
+		return 1
+	}
+	if delta >= durationCutoff {
+		return 0
+	}
+	return 1 - (delta-durationTolerance)/(durationCutoff-durationTolerance)
+}
+
+// tracksCloseness returns how close (0-1) the number of tracks of two albums are. e.g a deluxe edition with a few bonus
+// tracks is still close to the original
+func tracksCloseness(a, b int) float64 {
+	return 1 - math.Abs(float64(a-b))/math.Max(float64(a), float64(b))
+}
+
+// topTracksOverlap returns how much (0-1) two lists of top tracks overlap. It is measured against the shorter list since
+// platforms return different numbers of top tracks, and the top tracks are not always in the same order on every platform.
+func topTracksOverlap(a, b []types.SingleTrack) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	titles := map[string]bool{}
+	for _, track := range b {
+		titles[normalize.Title(track.Title)] = true
+	}
+	shared := 0
+	for _, track := range a {
+		if titles[normalize.Title(track.Title)] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a))
+}
+
+// splitArtistes returns the normalized names of the artistes. some platforms credit more than one artiste in a name
+// e.g "Wizkid feat. Tems"
+func splitArtistes(artistes []string) []string {
+	split := []string{}
+	for _, artiste := range artistes {
+		split = append(split, normalize.SplitArtistes(artiste)...)
+	}
+	return split
+}
@@ -0,0 +1,125 @@
+package platforms
+
+import (
+	"testing"
+	"zoove/errors"
+	"zoove/types"
+)
+
+func TestScoreCandidate(t *testing.T) {
+	search := &TrackToSearch{Title: "Essence (feat. Tems)", Artistes: []string{"Wizkid", "Tems"}, Album: "Made in Lagos",
+		Duration: 248000, Explicit: true}
+
+	tests := []struct {
+		name      string
+		candidate types.SingleTrack
+		expected  float64
+	}{
+		{name: "same track", expected: 1,
+			candidate: types.SingleTrack{Title: "Essence", Artistes: []string{"Wizkid", "Tems"}, Album: "Made In Lagos", Duration: 248000, Explicit: true}},
+		{name: "only the main artiste", expected: 1,
+			candidate: types.SingleTrack{Title: "Essence", Artistes: []string{"WizKid"}, Album: "Made in Lagos", Duration: 249000, Explicit: true}},
+		{name: "unknown album is left out", expected: 1,
+			candidate: types.SingleTrack{Title: "Essence", Artistes: []string{"Wizkid"}, Duration: 248000, Explicit: true}},
+		{name: "not explicit", expected: 0.95,
+			candidate: types.SingleTrack{Title: "Essence", Artistes: []string{"Wizkid"}, Album: "Made in Lagos", Duration: 248000}},
+		{name: "duration past the cutoff", expected: 0.8,
+			candidate: types.SingleTrack{Title: "Essence", Artistes: []string{"Wizkid"}, Album: "Made in Lagos", Duration: 300000, Explicit: true}},
+		{name: "duration half way to the cutoff", expected: 0.9,
+			candidate: types.SingleTrack{Title: "Essence", Artistes: []string{"Wizkid"}, Album: "Made in Lagos", Duration: 264000, Explicit: true}},
+		{name: "other album", expected: 0.9,
+			candidate: types.SingleTrack{Title: "Essence", Artistes: []string{"Wizkid"}, Album: "Essence", Duration: 248000, Explicit: true}},
+		{name: "other artiste", expected: 0.75,
+			candidate: types.SingleTrack{Title: "Essence", Artistes: []string{"Someone Else"}, Album: "Made in Lagos", Duration: 248000, Explicit: true}},
+		{name: "live version", expected: 0.8,
+			candidate: types.SingleTrack{Title: "Essence (Live)", Artistes: []string{"Wizkid"}, Album: "Made in Lagos", Duration: 248000, Explicit: true}},
+		{name: "other track", expected: 0.25,
+			candidate: types.SingleTrack{Title: "Ojuelegba", Artistes: []string{"Someone Else"}, Album: "Ayo", Duration: 248000, Explicit: true}},
+	}
+	for _, test := range tests {
+		if score := search.ScoreCandidate(&test.candidate); score != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, score)
+		}
+	}
+}
+
+func TestRankCandidates(t *testing.T) {
+	search := &TrackToSearch{Title: "Essence", Artistes: []string{"Wizkid"}, Duration: 248000}
+	best, err := search.RankCandidates([]types.SingleTrack{
+		{ID: "1", Title: "Essence (Live)", Artistes: []string{"Wizkid"}, Duration: 250000},
+		{ID: "2", Title: "Essence", Artistes: []string{"Wizkid"}, Duration: 248000},
+		{ID: "3", Title: "Ojuelegba", Artistes: []string{"Wizkid"}, Duration: 200000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.ID != "2" || best.Confidence != 1 {
+		t.Errorf("expected track 2 with confidence 1, got %s with %v", best.ID, best.Confidence)
+	}
+	if len(best.Alternatives) != 2 || best.Alternatives[0].ID != "1" {
+		t.Errorf("expected the runner ups as alternatives (best first), got %v", best.Alternatives)
+	}
+
+	_, err = search.RankCandidates([]types.SingleTrack{{ID: "3", Title: "Ojuelegba", Artistes: []string{"Burna Boy"}, Duration: 200000}})
+	if err != errors.NotFound {
+		t.Errorf("expected a candidate below MinConfidence not to be found, got %v", err)
+	}
+	_, err = search.RankCandidates(nil)
+	if err != errors.NotFound {
+		t.Errorf("expected no candidates not to be found, got %v", err)
+	}
+}
+
+func TestRankAlbums(t *testing.T) {
+	search := &AlbumToSearch{Title: "Made in Lagos", Artistes: []string{"Wizkid"}, TracksNumber: 14}
+	best, err := search.RankAlbums([]types.Album{
+		{ID: "1", Title: "Ayo", Artistes: []string{"Wizkid"}, TracksNumber: 19},
+		{ID: "2", Title: "Made in Lagos (Deluxe Edition)", Artistes: []string{"Wizkid"}, TracksNumber: 18},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.ID != "2" {
+		t.Errorf("expected album 2, got %s with %v", best.ID, best.Confidence)
+	}
+
+	// another artiste's album with the same title isnt the album searched for either
+	_, err = search.RankAlbums([]types.Album{
+		{ID: "1", Title: "Ayo", Artistes: []string{"Wizkid"}, TracksNumber: 19},
+		{ID: "3", Title: "Made in Lagos", Artistes: []string{"Someone Else"}, TracksNumber: 14},
+	})
+	if err != errors.NotFound {
+		t.Errorf("expected no album above MinAlbumConfidence not to be found, got %v", err)
+	}
+	_, err = search.RankAlbums(nil)
+	if err != errors.NotFound {
+		t.Errorf("expected no candidates not to be found, got %v", err)
+	}
+}
+
+func TestRankArtists(t *testing.T) {
+	search := &ArtistToSearch{Name: "Wizkid", TopTracks: []types.SingleTrack{{Title: "Essence"}, {Title: "Ojuelegba"}}}
+	best, err := search.RankArtists([]types.Artist{
+		{ID: "1", Name: "Wizkid", TopTracks: []types.SingleTrack{{Title: "Something Else"}}},
+		{ID: "2", Name: "WizKid", TopTracks: []types.SingleTrack{{Title: "Ojuelegba"}, {Title: "Essence (feat. Tems)"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.ID != "2" || best.Confidence != 1 {
+		t.Errorf("expected artiste 2 with confidence 1, got %s with %v", best.ID, best.Confidence)
+	}
+
+	// an artiste with the same name and none of the same top tracks is someone else
+	_, err = search.RankArtists([]types.Artist{
+		{ID: "1", Name: "Wizkid", TopTracks: []types.SingleTrack{{Title: "Something Else"}}},
+		{ID: "3", Name: "Burna Boy", TopTracks: []types.SingleTrack{{Title: "Ye"}}},
+	})
+	if err != errors.NotFound {
+		t.Errorf("expected no artiste above MinArtistConfidence not to be found, got %v", err)
+	}
+	_, err = search.RankArtists(nil)
+	if err != errors.NotFound {
+		t.Errorf("expected no candidates not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package platforms
+
+import (
+	"zoove/cache"
+	"zoove/types"
+)
+
+// Platform represents a streaming platform we can convert tracks and playlists from and to.
+// To support a new platform, implement this interface and call Register in an init func.
+type Platform interface {
+	// Name returns the host name of the platform, e.g "deezer". It is the same value used in types.ExtractedInfo.Host
+	Name() string
+	// GetSingleTrack returns a single (cached) track on the platform
+	GetSingleTrack(id string, store *cache.Cache) (*types.SingleTrack, error)
+	// SearchTrack searches the platform for a track and returns the best match
+	SearchTrack(search *TrackToSearch) (*types.SingleTrack, error)
+	// GetAlbum returns a (cached) album and its tracks
+	GetAlbum(id string, store *cache.Cache) (*types.Album, error)
+	// SearchAlbum searches the platform for an album and returns the best match with its tracks
+	SearchAlbum(search *AlbumToSearch) (*types.Album, error)
+	// GetArtist returns a (cached) artiste and their top tracks
+	GetArtist(id string, store *cache.Cache) (*types.Artist, error)
+	// SearchArtist searches the platform for an artiste and returns the best match with their top tracks
+	SearchArtist(search *ArtistToSearch) (*types.Artist, error)
+	// FetchPlaylistTracks returns a playlist and its tracks
+	FetchPlaylistTracks(id string, store *cache.Cache) (types.Playlist, error)
+	// PlaylistSnapshot returns the current snapshot of a playlist (see types.Playlist.Snapshot) without fetching its tracks
+	PlaylistSnapshot(id string) (string, error)
+	// CreatePlaylist creates a playlist with tracks for a user. token is the token stored for the user
+	CreatePlaylist(userID, title, token string, tracks []string) error
+	// UserAuth authorizes a user with an authcode and returns the user profile (and token to store) on the platform
+	UserAuth(authcode string) (*types.NewUser, error)
+	// FetchHistory returns the tracks a user recently played
+	FetchHistory(token string) ([]types.SingleTrack, error)
+}
+
+var registry = map[string]Platform{}
+var registered = []Platform{}
+
+// Register adds a platform to the registry. Platforms are returned by All in the order they were registered.
+func Register(platform Platform) {
+	if _, ok := registry[platform.Name()]; ok {
+		return
+	}
+	registry[platform.Name()] = platform
+	registered = append(registered, platform)
+}
+
+// Get returns the registered platform with the host name
+func Get(name string) (Platform, bool) {
+	platform, ok := registry[name]
+	return platform, ok
+}
+
+// All returns all the registered platforms
+func All() []Platform {
+	return registered
+}
+
+// Others returns all the registered platforms except the one with the host name
+func Others(name string) []Platform {
+	others := []Platform{}
+	for _, platform := range registered {
+		if platform.Name() != name {
+			others = append(others, platform)
+		}
+	}
+	return others
+}
@@ -12,107 +12,396 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"zoove/cache"
 	"zoove/errors"
+	"zoove/normalize"
 	"zoove/types"
 	"zoove/util"
 
-	"github.com/gomodule/redigo/redis"
-	"github.com/soveran/redisurl"
 	"github.com/zmb3/spotify"
 	"golang.org/x/oauth2"
 )
 
+// spotifyMarket is the market used to fetch things that differ by country (e.g the top tracks of an artiste)
+const spotifyMarket = "US"
+
 var scopes = url.QueryEscape(fmt.Sprintf("%s %s %s %s %s %s %s", spotify.ScopeUserReadPrivate, spotify.ScopeUserReadEmail,
 	spotify.ScopePlaylistModifyPublic, spotify.ScopeUserLibraryModify,
 	spotify.ScopeUserTopRead, spotify.ScopeUserReadRecentlyPlayed,
 	spotify.ScopeUserReadCurrentlyPlaying))
 
+// TokenCache caches the spotify client token (see GetSpotifyAuthToken). It caches nothing until main sets it
+var TokenCache = cache.New(nil)
+
+// Spotify is the spotify platform
+type Spotify struct{}
+
+func init() {
+	Register(&Spotify{})
+}
+
+// Name returns the host name of spotify
+func (*Spotify) Name() string {
+	return util.HostSpotify
+}
+
+// GetSingleTrack returns a single (cached) spotify track
+func (*Spotify) GetSingleTrack(id string, store *cache.Cache) (*types.SingleTrack, error) {
+	return HostSpotifyGetSingleTrack(id, store)
+}
+
+// SearchTrack searches spotify for a track
+func (*Spotify) SearchTrack(search *TrackToSearch) (*types.SingleTrack, error) {
+	return search.HostSpotifySearchTrack()
+}
+
+// GetAlbum returns a (cached) spotify album and its tracks
+func (*Spotify) GetAlbum(id string, store *cache.Cache) (*types.Album, error) {
+	return store.Album(fmt.Sprintf("%s-%s", util.HostSpotify, id), func() (*types.Album, error) {
+		return HostSpotifyGetAlbum(id)
+	})
+}
+
+// SearchAlbum searches spotify for an album
+func (*Spotify) SearchAlbum(search *AlbumToSearch) (*types.Album, error) {
+	return search.HostSpotifySearchAlbum()
+}
+
+// GetArtist returns a (cached) spotify artiste and their top tracks
+func (*Spotify) GetArtist(id string, store *cache.Cache) (*types.Artist, error) {
+	return store.Artist(fmt.Sprintf("%s-%s", util.HostSpotify, id), func() (*types.Artist, error) {
+		return HostSpotifyGetArtist(id)
+	})
+}
+
+// SearchArtist searches spotify for an artiste
+func (*Spotify) SearchArtist(search *ArtistToSearch) (*types.Artist, error) {
+	return search.HostSpotifySearchArtist()
+}
+
+// FetchPlaylistTracks returns a spotify playlist and its tracks
+func (*Spotify) FetchPlaylistTracks(id string, store *cache.Cache) (types.Playlist, error) {
+	return HostSpotifyFetchPlaylistTracks(id, store)
+}
+
+// PlaylistSnapshot returns the snapshot_id of a spotify playlist
+func (*Spotify) PlaylistSnapshot(id string) (string, error) {
+	return HostSpotifyPlaylistSnapshot(id)
+}
+
+// CreatePlaylist creates a spotify playlist for a user. token is the refresh token of the user
+func (*Spotify) CreatePlaylist(userID, title, token string, tracks []string) error {
+	spotifyTokens, err := HostSpotifyGetAuthorizedAcessToken(token)
+	if err != nil {
+		log.Println("Error getting correct access token for spotify")
+		return err
+	}
+	return HostSpotifyCreatePlaylist(userID, title, spotifyTokens.AccessToken, tracks)
+}
+
+// UserAuth authorizes a spotify user and returns the user profile. The token returned is the refresh token of the user
+func (*Spotify) UserAuth(authcode string) (*types.NewUser, error) {
+	user, refreshToken, err := HostSpotifyUserAuth(authcode)
+	if err != nil {
+		return nil, err
+	}
+
+	avatar := ""
+	if len(user.Images) > 0 {
+		avatar = user.Images[0].URL
+	}
+
+	return &types.NewUser{
+		Email:      user.Email,
+		Country:    user.Country,
+		Lang:       "en",
+		Username:   user.DisplayName,
+		Avatar:     avatar,
+		Platform:   util.HostSpotify,
+		Token:      refreshToken,
+		Plan:       user.Product,
+		PlatformID: user.ID,
+	}, nil
+}
+
+// FetchHistory returns the tracks a spotify user recently played. token is the refresh token of the user
+func (*Spotify) FetchHistory(token string) ([]types.SingleTrack, error) {
+	return HostSpotifyListeningHistory(token)
+}
+
 // HostSpotifySearchTrackChan returns a searched track using channels
 func (search *TrackToSearch) HostSpotifySearchTrackChan(ch chan *types.SingleTrack) {
-	payload := url.QueryEscape(fmt.Sprintf("track:%s artist:%s", search.Title, search.Artiste))
-	searchURL := fmt.Sprintf("%s/v1/search?q=%s&type=track", os.Getenv("SPOTIFY_API_BASE"), payload)
-	output := &types.HostSpotifySearchTrack{}
-	token, err := GetSpotifyAuthToken()
+	track, err := search.HostSpotifySearchTrack()
 	if err != nil {
-		// return nil, err
 		ch <- nil
+		return
 	}
+	ch <- track
+}
 
-	err = MakeSpotifyRequest(searchURL, token.AccessToken, output)
+// HostSpotifySearchTrack returns a searched track. It searches for the track by ISRC first and only falls back to
+// searching with the title and artiste when there is no ISRC match. The result is cached.
+func (search *TrackToSearch) HostSpotifySearchTrack() (*types.SingleTrack, error) {
+	return search.Cache.Track(cache.Search, search.cacheKey(util.HostSpotify), search.hostSpotifySearchTrack)
+}
+
+func (search *TrackToSearch) hostSpotifySearchTrack() (*types.SingleTrack, error) {
+	token, err := GetSpotifyAuthToken()
 	if err != nil {
-		// return nil, err
-		ch <- nil
+		return nil, err
 	}
-	// log.Printf("\nOUTPUT HERE: %#v\n\n", output.Tracks)
-	if len(output.Tracks.Items) > 0 {
 
-		if len(output.Tracks.Items[0].Artists) > 0 {
-			base := output.Tracks.Items[0]
-			artistes := []string{}
-			for i := range output.Tracks.Items[0].Artists {
-				artistes = append(artistes, output.Tracks.Items[0].Artists[i].Name)
-			}
-			track := &types.SingleTrack{
-				Cover:       base.Album.Images[0].URL,
-				Duration:    base.DurationMs,
-				Explicit:    base.Explicit,
-				ID:          base.ID,
-				Platform:    util.HostSpotify,
-				Preview:     base.PreviewURL,
-				ReleaseDate: base.Album.ReleaseDate,
-				Title:       base.Name,
-				URL:         base.ExternalUrls.Spotify,
-				Artistes:    artistes,
-			}
-			// return track, nil
-			ch <- track
-			// log.Printf("TITLE OF TRACK ON SPOTIFY IS: %s", track.Title)
-			return
+	if search.ISRC != "" {
+		tracks, err := hostSpotifySearchTracks(fmt.Sprintf("isrc:%s", search.ISRC), token.AccessToken, 1)
+		if err == nil && len(tracks) > 0 {
+			track := &tracks[0]
+			track.MatchStrategy = util.MatchStrategyISRC
+			track.Confidence = 1
+			return track, nil
 		}
+		log.Printf("Could not find track with ISRC %s on spotify. Searching instead\n", search.ISRC)
+	}
+
+	candidates, err := hostSpotifySearchTracks(fmt.Sprintf("track:%s artist:%s", normalize.SearchTitle(search.Title), search.Artiste), token.AccessToken, SearchCandidatesLimit)
+	if err != nil {
+		return nil, err
+	}
+	track, err := search.RankCandidates(candidates)
+	if err != nil {
+		return nil, err
 	}
-	// return nil, errors.NotFound
-	ch <- nil
+	track.MatchStrategy = util.MatchStrategySearch
+	return track, nil
 }
 
-// HostSpotifySearchTrack returns a searched track.
-func (search *TrackToSearch) HostSpotifySearchTrack() (*types.SingleTrack, error) {
-	payload := url.QueryEscape(fmt.Sprintf("track:%s artist:%s", search.Title, search.Artiste))
-	searchURL := fmt.Sprintf("%s/v1/search?q=%s&type=track", os.Getenv("SPOTIFY_API_BASE"), payload)
+// hostSpotifySearchTracks searches spotify with the query and returns (at most limit) tracks found
+func hostSpotifySearchTracks(query, token string, limit int) ([]types.SingleTrack, error) {
+	payload := url.QueryEscape(query)
+	searchURL := fmt.Sprintf("%s/v1/search?q=%s&type=track&limit=%d", os.Getenv("SPOTIFY_API_BASE"), payload, limit)
 	output := &types.HostSpotifySearchTrack{}
+	err := MakeSpotifyRequest(searchURL, token, output)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := []types.SingleTrack{}
+	for index := range output.Tracks.Items {
+		if len(output.Tracks.Items[index].Artists) == 0 {
+			continue
+		}
+		tracks = append(tracks, *hostSpotifyTrackToSingleTrack(&output.Tracks.Items[index]))
+	}
+	return tracks, nil
+}
+
+// hostSpotifyTrackToSingleTrack returns the SingleTrack of a spotify track
+func hostSpotifyTrackToSingleTrack(sptf *types.HostSpotifyTrack) *types.SingleTrack {
+	cover := ""
+	if len(sptf.Album.Images) > 0 {
+		cover = sptf.Album.Images[0].URL
+	}
+	single := &types.SingleTrack{
+		Cover:       cover,
+		Duration:    sptf.DurationMs,
+		Explicit:    sptf.Explicit,
+		ID:          sptf.ID,
+		Platform:    util.HostSpotify,
+		Preview:     sptf.PreviewURL,
+		ReleaseDate: sptf.Album.ReleaseDate,
+		Title:       sptf.Name,
+		URL:         sptf.ExternalUrls.Spotify,
+		Album:       sptf.Album.Name,
+		ISRC:        sptf.ExternalIds.Isrc,
+	}
+	for _, elem := range sptf.Artists {
+		single.Artistes = append(single.Artistes, elem.Name)
+	}
+	return single
+}
+
+// HostSpotifyGetAlbum returns a spotify album and its tracks
+func HostSpotifyGetAlbum(albumID string) (*types.Album, error) {
+	token, err := GetSpotifyAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	return hostSpotifyGetAlbum(albumID, token.AccessToken)
+}
+
+// hostSpotifyGetAlbum returns a spotify album and its tracks using the token
+func hostSpotifyGetAlbum(albumID, token string) (*types.Album, error) {
+	sptf := &types.HostSpotifyAlbum{}
+	err := MakeSpotifyRequest(fmt.Sprintf("%s/v1/albums/%s", os.Getenv("SPOTIFY_API_BASE"), albumID), token, sptf)
+	if err != nil {
+		return nil, err
+	}
+	if sptf.ID == "" {
+		return nil, errors.NotFound
+	}
+	return hostSpotifyAlbumToAlbum(sptf), nil
+}
+
+// HostSpotifySearchAlbum searches spotify for an album and returns it with its tracks. It searches for the album by UPC
+// first and only falls back to searching with the title and artiste when there is no UPC match.
+func (search *AlbumToSearch) HostSpotifySearchAlbum() (*types.Album, error) {
+	token, err := GetSpotifyAuthToken()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, upc := range UPCVariants(search.UPC) {
+		albums, err := hostSpotifySearchAlbums(fmt.Sprintf("upc:%s", upc), token.AccessToken, 1)
+		if err != nil || len(albums) == 0 {
+			continue
+		}
+		album, err := hostSpotifyGetAlbum(albums[0].ID, token.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		album.MatchStrategy = util.MatchStrategyUPC
+		album.Confidence = 1
+		return album, nil
+	}
+	if search.UPC != "" {
+		log.Printf("Could not find album with UPC %s on spotify. Searching instead\n", search.UPC)
+	}
+
+	candidates, err := hostSpotifySearchAlbums(fmt.Sprintf("album:%s artist:%s", normalize.SearchTitle(search.Title), search.Artiste), token.AccessToken, SearchCandidatesLimit)
+	if err != nil {
+		return nil, err
+	}
+	best, err := search.RankAlbums(candidates)
+	if err != nil {
+		return nil, err
+	}
+	// search results dont have the tracks of the album
+	album, err := hostSpotifyGetAlbum(best.ID, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	album.MatchStrategy = util.MatchStrategySearch
+	album.Confidence = best.Confidence
+	return album, nil
+}
+
+// hostSpotifySearchAlbums searches spotify with the query and returns (at most limit) albums found, without their tracks
+func hostSpotifySearchAlbums(query, token string, limit int) ([]types.Album, error) {
+	payload := url.QueryEscape(query)
+	searchURL := fmt.Sprintf("%s/v1/search?q=%s&type=album&limit=%d", os.Getenv("SPOTIFY_API_BASE"), payload, limit)
+	output := &types.HostSpotifySearchAlbum{}
+	err := MakeSpotifyRequest(searchURL, token, output)
+	if err != nil {
+		return nil, err
+	}
+
+	albums := []types.Album{}
+	for index := range output.Albums.Items {
+		albums = append(albums, *hostSpotifyAlbumToAlbum(&output.Albums.Items[index]))
+	}
+	return albums, nil
+}
+
+// hostSpotifyAlbumToAlbum returns the Album of a spotify album
+func hostSpotifyAlbumToAlbum(sptf *types.HostSpotifyAlbum) *types.Album {
+	cover := ""
+	if len(sptf.Images) > 0 {
+		cover = sptf.Images[0].URL
+	}
+	album := &types.Album{Title: sptf.Name, URL: sptf.ExternalUrls.Spotify, Cover: cover, ReleaseDate: sptf.ReleaseDate,
+		UPC: sptf.ExternalIds.Upc, TracksNumber: sptf.TotalTracks, Platform: util.HostSpotify, ID: sptf.ID,
+		Tracks: []types.SingleTrack{}}
+	for _, artist := range sptf.Artists {
+		album.Artistes = append(album.Artistes, artist.Name)
+	}
+	for _, track := range sptf.Tracks.Items {
+		single := types.SingleTrack{Cover: cover, Duration: track.DurationMs, Explicit: track.Explicit, ID: track.ID,
+			Platform: util.HostSpotify, Preview: track.PreviewURL, ReleaseDate: sptf.ReleaseDate, Title: track.Name,
+			URL: track.ExternalUrls.Spotify, Album: sptf.Name}
+		for _, artist := range track.Artists {
+			single.Artistes = append(single.Artistes, artist.Name)
+		}
+		album.Duration += track.DurationMs
+		album.Explicit = album.Explicit || track.Explicit
+		album.Tracks = append(album.Tracks, single)
+	}
+	return album
+}
+
+// HostSpotifyGetArtist returns a spotify artiste and their top tracks
+func HostSpotifyGetArtist(artistID string) (*types.Artist, error) {
+	token, err := GetSpotifyAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	return hostSpotifyGetArtist(artistID, token.AccessToken)
+}
+
+// hostSpotifyGetArtist returns a spotify artiste and their top tracks using the token
+func hostSpotifyGetArtist(artistID, token string) (*types.Artist, error) {
+	spotifyAPIBase := os.Getenv("SPOTIFY_API_BASE")
+	sptf := &types.HostSpotifyArtist{}
+	err := MakeSpotifyRequest(fmt.Sprintf("%s/v1/artists/%s", spotifyAPIBase, artistID), token, sptf)
+	if err != nil {
+		return nil, err
+	}
+	if sptf.ID == "" {
+		return nil, errors.NotFound
+	}
+
+	top := &types.HostSpotifyArtistTopTracks{}
+	err = MakeSpotifyRequest(fmt.Sprintf("%s/v1/artists/%s/top-tracks?market=%s", spotifyAPIBase, artistID, spotifyMarket), token, top)
+	if err != nil {
+		log.Println("Error fetching spotify artiste top tracks")
+		return nil, err
+	}
+
+	artist := hostSpotifyArtistToArtist(sptf)
+	artist.TopTracks = []types.SingleTrack{}
+	for index := range top.Tracks {
+		if len(artist.TopTracks) == TopTracksLimit {
+			break
+		}
+		artist.TopTracks = append(artist.TopTracks, *hostSpotifyTrackToSingleTrack(&top.Tracks[index]))
+	}
+	return artist, nil
+}
+
+// HostSpotifySearchArtist searches spotify for an artiste and returns them with their top tracks. The artistes found are
+// told apart using their top tracks
+func (search *ArtistToSearch) HostSpotifySearchArtist() (*types.Artist, error) {
 	token, err := GetSpotifyAuthToken()
 	if err != nil {
 		return nil, err
 	}
 
+	payload := url.QueryEscape(fmt.Sprintf("artist:%s", search.Name))
+	searchURL := fmt.Sprintf("%s/v1/search?q=%s&type=artist&limit=%d", os.Getenv("SPOTIFY_API_BASE"), payload, ArtistCandidatesLimit)
+	output := &types.HostSpotifySearchArtist{}
 	err = MakeSpotifyRequest(searchURL, token.AccessToken, output)
 	if err != nil {
 		return nil, err
 	}
-	// log.Printf("\nOUTPUT HERE: %#v\n\n", output.Tracks)
-	if len(output.Tracks.Items) > 0 {
 
-		if len(output.Tracks.Items[0].Artists) > 0 {
-			base := output.Tracks.Items[0]
-			artistes := []string{}
-			for i := range output.Tracks.Items[0].Artists {
-				artistes = append(artistes, output.Tracks.Items[0].Artists[i].Name)
-			}
-			track := &types.SingleTrack{
-				Cover:       base.Album.Images[0].URL,
-				Duration:    base.DurationMs,
-				Explicit:    base.Explicit,
-				ID:          base.ID,
-				Platform:    util.HostSpotify,
-				Preview:     base.PreviewURL,
-				ReleaseDate: base.Album.ReleaseDate,
-				Title:       base.Name,
-				URL:         base.ExternalUrls.Spotify,
-				Artistes:    artistes,
-			}
-			return track, nil
+	candidates := []types.Artist{}
+	for _, base := range output.Artists.Items {
+		artist, err := hostSpotifyGetArtist(base.ID, token.AccessToken)
+		if err != nil {
+			log.Printf("Error fetching spotify artiste %s. Skipping\n", base.ID)
+			continue
 		}
+		candidates = append(candidates, *artist)
 	}
-	return nil, errors.NotFound
+	return search.RankArtists(candidates)
+}
+
+// hostSpotifyArtistToArtist returns the Artist of a spotify artiste (without the top tracks)
+func hostSpotifyArtistToArtist(sptf *types.HostSpotifyArtist) *types.Artist {
+	picture := ""
+	if len(sptf.Images) > 0 {
+		picture = sptf.Images[0].URL
+	}
+	return &types.Artist{Name: sptf.Name, URL: sptf.ExternalUrls.Spotify, Picture: picture, Fans: sptf.Followers.Total,
+		Genres: sptf.Genres, Platform: util.HostSpotify, ID: sptf.ID}
 }
 
 // HostSpotifyReturnAuth returns a new oauth token for spotify user. Note this is not used used for making calls that require user permission
@@ -189,119 +478,29 @@ func HostSpotifyUserAuth(authcode string) (*spotify.PrivateUser, string, error)
 }
 
 // HostSpotifyGetSingleTrackChan returns a single (cached) spotify track but using a channel
-func HostSpotifyGetSingleTrackChan(spotifyID string, pool *redis.Pool, ch chan *types.SingleTrack) {
-	conn := pool.Get()
-	defer conn.Close()
-	key := fmt.Sprintf("%s-%s", "spotify", spotifyID)
-	values, err := redis.String(conn.Do("GET", key))
-	if err != nil {
-		// log.Println("Error getting single track")
-		if err == redis.ErrNil {
-			tokens, err := GetSpotifyAuthToken()
-			if err != nil {
-				// return nil, err
-				ch <- nil
-			}
-
-			sptf := &types.HostSpotifyTrack{}
-			err = MakeSpotifyRequest(fmt.Sprintf("%s/v1/tracks/%s", os.Getenv("spotifyApiBase"), spotifyID), tokens.AccessToken, sptf)
-
-			single := &types.SingleTrack{
-				Cover:       sptf.Album.Images[0].URL,
-				Duration:    sptf.DurationMs,
-				Explicit:    sptf.Explicit,
-				ID:          sptf.ID,
-				Platform:    util.HostSpotify,
-				Preview:     sptf.PreviewURL,
-				ReleaseDate: sptf.Album.ReleaseDate,
-				Title:       sptf.Name,
-				URL:         sptf.ExternalUrls.Spotify,
-			}
-			for _, elem := range sptf.Artists {
-				single.Artistes = append(single.Artistes, elem.Name)
-			}
-
-			serialize, err := json.Marshal(single)
-			if err != nil {
-				// return nil, err
-				ch <- nil
-			}
-			_, err = redis.String(conn.Do("SET", key, string(serialize)))
-			if err != nil {
-				// just log. not handling this error as its none crucial. users dont care it doesnt impact them
-				log.Println("Error inserting into redis")
-				log.Println(err)
-			}
-			// return single, err
-			ch <- single
-			return
-		}
-	}
-
-	single := &types.SingleTrack{}
-	err = json.Unmarshal([]byte(values), single)
+func HostSpotifyGetSingleTrackChan(spotifyID string, store *cache.Cache, ch chan *types.SingleTrack) {
+	track, err := HostSpotifyGetSingleTrack(spotifyID, store)
 	if err != nil {
-		// return nil, err
 		ch <- nil
 		return
 	}
-
-	ch <- single
+	ch <- track
 }
 
 // HostSpotifyGetSingleTrack returns a single (cached) spotify track
-func HostSpotifyGetSingleTrack(spotifyID string, pool *redis.Pool) (*types.SingleTrack, error) {
-	conn := pool.Get()
-	defer conn.Close()
-	key := fmt.Sprintf("%s-%s", "spotify", spotifyID)
-	values, err := redis.String(conn.Do("GET", key))
-	if err != nil {
-		// log.Println("Error getting single track")
-		if err == redis.ErrNil {
-			tokens, err := GetSpotifyAuthToken()
-			if err != nil {
-				return nil, err
-			}
-
-			sptf := &types.HostSpotifyTrack{}
-			err = MakeSpotifyRequest(fmt.Sprintf("%s/v1/tracks/%s", os.Getenv("SPOTIFY_API_BASE"), spotifyID), tokens.AccessToken, sptf)
-			single := &types.SingleTrack{
-				Cover:       sptf.Album.Images[0].URL,
-				Duration:    sptf.DurationMs,
-				Explicit:    sptf.Explicit,
-				ID:          sptf.ID,
-				Platform:    util.HostSpotify,
-				Preview:     sptf.PreviewURL,
-				ReleaseDate: sptf.Album.ReleaseDate,
-				Title:       sptf.Name,
-				URL:         sptf.ExternalUrls.Spotify,
-				Album:       sptf.Album.Name,
-			}
-			for _, elem := range sptf.Artists {
-				single.Artistes = append(single.Artistes, elem.Name)
-			}
-
-			serialize, err := json.Marshal(single)
-			if err != nil {
-				return nil, err
-			}
-			_, err = redis.String(conn.Do("SET", key, string(serialize)))
-			if err != nil {
-				// just log. not handling this error as its none crucial. users dont care it doesnt impact them
-				log.Println("Error inserting into redis")
-				log.Println(err)
-			}
-			return single, err
+func HostSpotifyGetSingleTrack(spotifyID string, store *cache.Cache) (*types.SingleTrack, error) {
+	return store.Track(cache.Track, fmt.Sprintf("%s-%s", util.HostSpotify, spotifyID), func() (*types.SingleTrack, error) {
+		tokens, err := GetSpotifyAuthToken()
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	single := &types.SingleTrack{}
-	err = json.Unmarshal([]byte(values), single)
-	if err != nil {
-		return nil, err
-	}
-
-	return single, nil
+		sptf := &types.HostSpotifyTrack{}
+		err = MakeSpotifyRequest(fmt.Sprintf("%s/v1/tracks/%s", os.Getenv("SPOTIFY_API_BASE"), spotifyID), tokens.AccessToken, sptf)
+		if err != nil {
+			return nil, err
+		}
+		return hostSpotifyTrackToSingleTrack(sptf), nil
+	})
 }
 
 // HostSpotifyListeningHistory returns the listening history for a spotify user
@@ -380,8 +579,13 @@ func HostSpotifyGetAuthorizedAcessToken(refreshToken string) (*types.HostSpotify
 	return authRes, nil
 }
 
-// GetSpotifyAuthToken returns a normal spotify oauth token for a us. this token is used for things that dont require user permission or scopes
+// GetSpotifyAuthToken returns a normal spotify oauth token for a us. this token is used for things that dont require user permission or scopes.
+// It is cached in TokenCache until shortly before it expires.
 func GetSpotifyAuthToken() (*oauth2.Token, error) {
+	return TokenCache.Token(util.HostSpotify, hostSpotifyFetchAuthToken)
+}
+
+func hostSpotifyFetchAuthToken() (*oauth2.Token, error) {
 	spotifyClientID := os.Getenv("SPOTIFY_CLIENT_ID")
 	spotifyClientSecret := os.Getenv("SPOTIFY_CLIENT_SECRET")
 	spotifyBearer := base64.StdEncoding.EncodeToString([]byte(spotifyClientID + ":" + spotifyClientSecret))
@@ -437,24 +641,34 @@ func HostSpotifyFetchArtisteHistory(token string) ([]string, error) {
 }
 
 // HostSpotifyFetchPlaylistTracks returns a cached spotify playlist
-func HostSpotifyFetchPlaylistTracks(playlistID string, pool *redis.Pool) (types.Playlist, error) {
-	// log.Printf("PLAYLIST IS %s\n", playlistID)
-	pool = &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			// log.Println(os.Getenv("REDIS_URL"))
-			return redisurl.Connect()
-		},
+func HostSpotifyFetchPlaylistTracks(playlistID string, store *cache.Cache) (types.Playlist, error) {
+	return store.Playlist(PlaylistCacheID(util.HostSpotify, playlistID), func() (types.Playlist, error) {
+		return hostSpotifyFetchPlaylistTracks(playlistID)
+	})
+}
+
+// HostSpotifyPlaylistSnapshot returns the current snapshot_id of a spotify playlist. It changes whenever the playlist does
+func HostSpotifyPlaylistSnapshot(playlistID string) (string, error) {
+	tok, err := GetSpotifyAuthToken()
+	if err != nil {
+		return "", err
+	}
+	client := spotify.NewAuthenticator(os.Getenv("SPOTIFY_REDIRECT_URI"), scopes).NewClient(tok)
+	// only the snapshot_id is returned, not the tracks
+	spotifyPlaylist, err := client.GetPlaylistOpt(spotify.ID(playlistID), "snapshot_id")
+	if err != nil {
+		return "", err
 	}
+	return spotifyPlaylist.SnapshotID, nil
+}
 
+func hostSpotifyFetchPlaylistTracks(playlistID string) (types.Playlist, error) {
 	tok, err := GetSpotifyAuthToken()
 	if err != nil {
 		return types.Playlist{}, err
 	}
 	// log.Printf("\nReturned token: %#v", tok.AccessToken)
 
-	conn := pool.Get()
-	defer conn.Close()
-
 	auth := spotify.NewAuthenticator(os.Getenv("SPOTIFY_REDIRECT_URI"), scopes)
 	client := auth.NewClient(tok)
 	spotifyPlaylist, err := client.GetPlaylist(spotify.ID(playlistID))
@@ -471,41 +685,60 @@ func HostSpotifyFetchPlaylistTracks(playlistID string, pool *redis.Pool) (types.
 		Title:         spotifyPlaylist.Name,
 		Owner: types.PlaylistOwner{Avatar: avatar, ID: spotifyPlaylist.Owner.ID,
 			Name: spotifyPlaylist.Name},
-		URL:   spotifyPlaylist.ExternalURLs["spotify"],
-		Cover: spotifyPlaylist.Images[0].URL,
-	}
-	for _, single := range spotifyPlaylist.Tracks.Tracks {
-		durationMs += single.Track.Duration
-		img := ""
-		if len(single.Track.Album.Images) > 0 {
-			img = single.Track.Album.Images[0].URL
+		URL:          spotifyPlaylist.ExternalURLs["spotify"],
+		TracksNumber: spotifyPlaylist.Tracks.Total,
+		Snapshot:     spotifyPlaylist.SnapshotID,
+	}
+	if len(spotifyPlaylist.Images) > 0 {
+		playlist.Cover = spotifyPlaylist.Images[0].URL
+	}
+	limit := PlaylistTracksLimit()
+	// the playlist only has the first page of tracks. the rest are fetched page by page until the limit
+	page := &spotifyPlaylist.Tracks
+	for {
+		for _, single := range page.Tracks {
+			if len(playlist.Tracks) >= limit {
+				break
+			}
+			durationMs += single.Track.Duration
+			img := ""
+			if len(single.Track.Album.Images) > 0 {
+				img = single.Track.Album.Images[0].URL
+			}
+			singleT := &types.SingleTrack{
+				AddedAt:     single.AddedAt,
+				Cover:       img,
+				Duration:    single.Track.Duration,
+				Explicit:    single.Track.Explicit,
+				ID:          single.Track.ID.String(),
+				Platform:    util.HostSpotify,
+				Title:       single.Track.Name,
+				URL:         single.Track.Endpoint,
+				ReleaseDate: single.Track.Album.ReleaseDate,
+				Preview:     single.Track.PreviewURL,
+				Album:       single.Track.Album.Name,
+				ISRC:        single.Track.ExternalIDs["isrc"],
+			}
+			for _, r := range single.Track.Artists {
+				singleT.Artistes = append(singleT.Artistes, r.Name)
+			}
+			playlist.Tracks = append(playlist.Tracks, *singleT)
 		}
-		singleT := &types.SingleTrack{
-			AddedAt:     single.AddedAt,
-			Cover:       img,
-			Duration:    single.Track.Duration,
-			Explicit:    single.Track.Explicit,
-			ID:          single.Track.ID.String(),
-			Platform:    util.HostSpotify,
-			Title:       single.Track.Name,
-			URL:         single.Track.Endpoint,
-			ReleaseDate: single.Track.Album.ReleaseDate,
-			Preview:     single.Track.PreviewURL,
-			Album:       single.Track.Album.Name,
+		if len(playlist.Tracks) >= limit {
+			break
 		}
-		for _, r := range single.Track.Artists {
-			singleT.Artistes = append(singleT.Artistes, r.Name)
+		err = client.NextPage(page)
+		if err == spotify.ErrNoMorePages {
+			break
+		}
+		if err != nil {
+			log.Println("Error fetching the next page of spotify playlist tracks")
+			log.Println(err)
+			return types.Playlist{}, err
 		}
-		playlist.Tracks = append(playlist.Tracks, *singleT)
 	}
 	playlist.Duration = durationMs
-
-	if err != nil {
-		return types.Playlist{}, nil
-	}
-	if err != nil {
-		return types.Playlist{}, nil
-	}
+	playlist.FetchedTracks = len(playlist.Tracks)
 
 	// log.Println("Tracks found for the playlist is: ", playlist)
 	return playlist, nil
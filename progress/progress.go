@@ -0,0 +1,123 @@
+// Package progress keeps the progress of conversions in the cache backend (see cache.Backend) so that many clients (and clients that reconnect) can
+// follow a conversion that is run once.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+	"zoove/cache"
+	"zoove/types"
+)
+
+const (
+	// DefaultTTL is how long the progress of a conversion is kept once it is no longer being added to
+	DefaultTTL = 10 * time.Minute
+	// DefaultLockTTL is how long a conversion can run before another one can take its place
+	DefaultLockTTL = 5 * time.Minute
+)
+
+// Event is an event of a conversion. ID is its position in the log (starting from 1) and Name is either "meta",
+// "track", "done" or "error".
+type Event struct {
+	ID   int             `json:"-"`
+	Name string          `json:"event"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Finished returns true if the event is the last event of a conversion
+func (event *Event) Finished() bool {
+	return event.Name == "done" || event.Name == "error"
+}
+
+// Log is the progress of conversions, kept as lists of events
+type Log struct {
+	Backend cache.Backend
+	TTL     time.Duration
+	LockTTL time.Duration
+}
+
+// NewLog returns a new Log with the default TTLs
+func NewLog(backend cache.Backend) *Log {
+	return &Log{Backend: backend, TTL: DefaultTTL, LockTTL: DefaultLockTTL}
+}
+
+// Key returns the key of the progress of the conversion of the extracted link
+func Key(extracted *types.ExtractedInfo) string {
+	return fmt.Sprintf("progress-%s-%s-%s", extracted.Host, extracted.Type, extracted.ID)
+}
+
+// Lock makes the caller the only one running the conversion with the key. It returns false if another conversion is
+// already running.
+func (progress *Log) Lock(key string) (bool, error) {
+	return progress.Backend.SetNX(lockKey(key), []byte("1"), progress.LockTTL)
+}
+
+// Unlock lets another conversion with the key run
+func (progress *Log) Unlock(key string) {
+	err := progress.Backend.Delete(lockKey(key))
+	if err != nil {
+		log.Println("Error unlocking conversion progress")
+		log.Println(err)
+	}
+}
+
+// Reset removes the events of the conversion with the key
+func (progress *Log) Reset(key string) error {
+	return progress.Backend.Delete(key)
+}
+
+// Append adds an event to the conversion with the key
+func (progress *Log) Append(key, name string, data interface{}) error {
+	serialized, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	event, err := json.Marshal(&Event{Name: name, Data: serialized})
+	if err != nil {
+		return err
+	}
+
+	return progress.Backend.Append(key, event, progress.TTL)
+}
+
+// Since returns the events of the conversion with the key after the event with the ID lastID (all of them when it is 0)
+// and whether the conversion has finished. It returns no events and false when there is no conversion with the key.
+func (progress *Log) Since(key string, lastID int) ([]Event, bool, error) {
+	values, err := progress.Backend.Range(key, lastID, -1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(values) == 0 {
+		// the client might already have every event. check the last one
+		last, err := progress.Backend.Range(key, -1, -1)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(last) == 0 {
+			return nil, false, nil
+		}
+		event := &Event{}
+		if err := json.Unmarshal(last[0], event); err != nil {
+			return nil, false, err
+		}
+		return nil, event.Finished(), nil
+	}
+
+	events := make([]Event, 0, len(values))
+	for index, value := range values {
+		event := Event{}
+		if err := json.Unmarshal(value, &event); err != nil {
+			return nil, false, err
+		}
+		event.ID = lastID + index + 1
+		events = append(events, event)
+	}
+	return events, events[len(events)-1].Finished(), nil
+}
+
+// lockKey returns the key of the lock of the conversion with the key
+func lockKey(key string) string {
+	return fmt.Sprintf("%s-lock", key)
+}
@@ -0,0 +1,228 @@
+// Package socket implements the v2 websocket protocol (see docs/websocket.md). A connection stays open between requests
+// and can have many requests running at the same time. Every frame sent has the ID of the request it is for.
+package socket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"zoove/converter"
+	"zoove/db"
+	"zoove/errors"
+	"zoove/platforms"
+	"zoove/types"
+	"zoove/util"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// MaxInFlight is the max number of requests a connection can have running at the same time
+const MaxInFlight = 8
+
+// Session is a websocket connection using the v2 protocol
+type Session struct {
+	Conn      *websocket.Conn
+	Converter *converter.Converter
+	Resolver  *util.LinkResolver
+	Client    *db.PrismaClient
+	// UUID is the uuid of the user authenticated when connecting (see middleware.AuthenticateSocket). It is empty when
+	// the connection has no user, then it cant create playlists.
+	UUID string
+
+	// writeMutex guards the connection since the requests write their frames from their own goroutines
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	requests   map[string]context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// NewSession returns a new session for the connection of the user with the uuid (empty when it has no user)
+func NewSession(conn *websocket.Conn, converter *converter.Converter, resolver *util.LinkResolver, client *db.PrismaClient, uuid string) *Session {
+	return &Session{Conn: conn, Converter: converter, Resolver: resolver, Client: client, UUID: uuid,
+		requests: map[string]context.CancelFunc{}}
+}
+
+// Serve reads the requests of the client until the connection is closed. The requests still running are cancelled
+// when the connection is closed and Serve returns once they are done.
+func (session *Session) Serve() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		session.wg.Wait()
+	}()
+
+	for {
+		_, msg, err := session.Conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure, websocket.CloseAbnormalClosure) {
+				log.Println("Read Error:", err)
+			}
+			return
+		}
+
+		request := &types.SocketRequest{}
+		err = json.Unmarshal(msg, request)
+		if err != nil || request.ID == "" {
+			log.Println("Error parsing. Seems client is sending non-json data or a request without an ID")
+			session.writeError(request.ID, util.ErrorCodeInvalidRequest, "requests must be JSON objects with an id and a type")
+			continue
+		}
+		session.handle(ctx, request)
+	}
+}
+
+// handle starts a request (or cancels one)
+func (session *Session) handle(ctx context.Context, request *types.SocketRequest) {
+	if request.Type == "cancel" {
+		session.cancel(request.ID)
+		return
+	}
+
+	var run func(context.Context, *types.SocketRequest) (interface{}, error)
+	switch request.Type {
+	case "convert":
+		run = session.convert
+	case "create_playlist":
+		run = session.createPlaylist
+	default:
+		session.writeError(request.ID, util.ErrorCodeUnknownType, fmt.Sprintf("unknown request type %q", request.Type))
+		return
+	}
+
+	session.mutex.Lock()
+	if _, ok := session.requests[request.ID]; ok {
+		session.mutex.Unlock()
+		session.writeError(request.ID, util.ErrorCodeDuplicateID, "a request with the same id is still running")
+		return
+	}
+	if len(session.requests) >= MaxInFlight {
+		session.mutex.Unlock()
+		session.writeError(request.ID, util.ErrorCodeTooManyRequests, fmt.Sprintf("at most %d requests can run at the same time", MaxInFlight))
+		return
+	}
+	requestCtx, cancel := context.WithCancel(ctx)
+	session.requests[request.ID] = cancel
+	session.mutex.Unlock()
+
+	session.wg.Add(1)
+	go func() {
+		defer session.wg.Done()
+		defer session.done(request.ID)
+
+		payload, err := run(requestCtx, request)
+		// the result is dropped if the request was cancelled while it ran
+		if err == nil {
+			err = requestCtx.Err()
+		}
+		if err != nil {
+			log.Printf("Error running %s request %s\n", request.Type, request.ID)
+			log.Println(err)
+			session.writeError(request.ID, util.ErrorCode(err), err.Error())
+			return
+		}
+		session.write(&types.SocketFrame{ID: request.ID, Type: "result", Payload: payload})
+	}()
+}
+
+// cancel cancels a running request. The request replies with a cancelled error
+func (session *Session) cancel(id string) {
+	session.mutex.Lock()
+	cancel, ok := session.requests[id]
+	session.mutex.Unlock()
+	if !ok {
+		session.writeError(id, util.ErrorCodeUnknownRequest, "there is no running request with the id")
+		return
+	}
+	cancel()
+}
+
+// done removes a request from the running requests
+func (session *Session) done(id string) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if cancel, ok := session.requests[id]; ok {
+		cancel()
+		delete(session.requests, id)
+	}
+}
+
+// convert converts the link of the request. The result is the same as the v2 REST API's
+func (session *Session) convert(ctx context.Context, request *types.SocketRequest) (interface{}, error) {
+	session.progress(request.ID, "resolving")
+	link, err := session.Resolver.Resolve(request.URL)
+	if err != nil {
+		return nil, err
+	}
+	extracted, err := util.ExtractInfoMetadata(link)
+	if err != nil {
+		return nil, err
+	}
+
+	session.progress(request.ID, "converting")
+	// playlists are sent a track at a time so that clients can show them before the whole playlist is converted
+	onPlaylist := func(playlist *types.Playlist) {
+		meta := *playlist
+		meta.Tracks = nil
+		session.write(&types.SocketFrame{ID: request.ID, Type: "progress", Payload: &types.SocketProgress{Stage: "playlist", Playlist: &meta}})
+	}
+	onTrack := func(index int, conversion *types.Conversion) {
+		session.write(&types.SocketFrame{ID: request.ID, Type: "progress", Payload: &types.SocketProgress{Stage: "track", Index: &index, Track: conversion}})
+	}
+	result, err := session.Converter.StreamConvert(ctx, extracted, onPlaylist, onTrack)
+	if err != nil {
+		return nil, err
+	}
+	if result.Playlist != nil {
+		session.write(&types.SocketFrame{ID: request.ID, Type: "progress", Payload: &types.SocketProgress{Stage: "summary", Summary: result.Playlist.Summary}})
+	}
+	return result, nil
+}
+
+// createPlaylist creates a playlist for the user of the session
+func (session *Session) createPlaylist(ctx context.Context, request *types.SocketRequest) (interface{}, error) {
+	if session.UUID == "" {
+		return nil, errors.UnAuthorized
+	}
+	existing, err := session.Client.User.FindOne(db.User.UUID.Equals(session.UUID)).Exec(ctx)
+	if err == db.ErrNotFound {
+		return nil, errors.UnAuthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	session.progress(request.ID, "creating")
+	created := make(chan bool)
+	go platforms.CreatePlaylistChan(existing.PlatformID, request.Payload.Title, existing.Token, request.Payload.Platform, request.Payload.Tracks, created)
+	select {
+	case ok := <-created:
+		return ok, nil
+	case <-ctx.Done():
+		// CreatePlaylistChan still sends its result so it doesnt block forever
+		go func() { <-created }()
+		return nil, ctx.Err()
+	}
+}
+
+// progress sends a progress frame for a request
+func (session *Session) progress(id, stage string) {
+	session.write(&types.SocketFrame{ID: id, Type: "progress", Payload: &types.SocketProgress{Stage: stage}})
+}
+
+// writeError sends an error frame for a request
+func (session *Session) writeError(id, code, message string) {
+	session.write(&types.SocketFrame{ID: id, Type: "error", Error: &types.SocketError{Code: code, Message: message}})
+}
+
+// write sends a frame to the client. It is safe to call from many goroutines
+func (session *Session) write(frame *types.SocketFrame) {
+	session.writeMutex.Lock()
+	defer session.writeMutex.Unlock()
+	err := session.Conn.WriteJSON(frame)
+	if err != nil {
+		log.Println("Error writing socket frame")
+		log.Println(err)
+	}
+}
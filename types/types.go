@@ -20,6 +20,13 @@ type SingleTrack struct {
 	PlayedAt    string   `json:"played_at,omitempty"` // this is because this struct is also used for the single listening history object which contains (and needs) a "when was it played" body which is this.
 	AddedAt     string   `json:"added_at,omitempty"`  // similar situation above but in this case, its for Playlists. To know when a track was added to a playlist.
 	Album       string   `json:"album"`
+	ISRC        string   `json:"isrc"`
+	// MatchStrategy is how the track was found when searched from another platform's track. Either "isrc" or "search".
+	MatchStrategy string `json:"match_strategy,omitempty"`
+	// Confidence (0-1) is how sure we are that a searched track is the same as the track searched for.
+	Confidence float64 `json:"confidence,omitempty"`
+	// Alternatives are the runner up search results, best first.
+	Alternatives []SingleTrack `json:"alternatives,omitempty"`
 }
 type Playlist struct {
 	Title         string        `json:"title"`
@@ -31,6 +38,326 @@ type Playlist struct {
 	Tracks        []SingleTrack `json:"tracks"`
 	URL           string        `json:"playlist_url"`
 	Cover         string        `json:"playlist_cover"`
+	// FetchedTracks is the number of tracks fetched. It is less than TracksNumber when the playlist has more tracks than
+	// we fetch (see platforms.PlaylistTracksLimit)
+	FetchedTracks int `json:"fetched_tracks"`
+	// Snapshot changes whenever the playlist (or its tracks) does. It is the checksum of a deezer playlist and the
+	// snapshot_id of a spotify one.
+	Snapshot string `json:"snapshot"`
+}
+
+// TrackConversion is a track found on every platform from the track on one
+type TrackConversion struct {
+	// Tracks is the track on each platform. It is null for a platform the track wasnt found on.
+	Tracks map[string]*SingleTrack `json:"tracks"`
+	// Errors is why the track wasnt found, for each platform it wasnt found on.
+	Errors map[string]*TrackError `json:"errors,omitempty"`
+	// ConvertedAt is when the track was converted. A conversion reused from the last conversion of a playlist keeps it.
+	ConvertedAt time.Time `json:"converted_at"`
+}
+
+// TrackMapping is a track on a platform that is known to be the same as a track on another platform (see converter.Store)
+type TrackMapping struct {
+	Platform   string
+	PlatformID string
+	ISRC       string
+	// Confidence (0-1) is how sure we are that the tracks are the same
+	Confidence float64
+	// Source is how the track was matched (see SingleTrack.MatchStrategy)
+	Source    string
+	MatchedAt time.Time
+}
+
+// MatchReport is a user's report that a track was converted to the wrong track on a platform (see mappings.PrismaStore)
+type MatchReport struct {
+	ID             int    `json:"id"`
+	UserUUID       string `json:"user_uuid"`
+	SourcePlatform string `json:"source_platform"`
+	SourceID       string `json:"source_id"`
+	TargetPlatform string `json:"target_platform"`
+	// TargetID is the wrong track. It is empty when the track wasnt found at all
+	TargetID string `json:"target_id"`
+	// CorrectedID is the track it should have been. It is empty when the user doesnt know
+	CorrectedID string `json:"corrected_id"`
+	// Status is one of open, pinned or dismissed
+	Status string `json:"status"`
+	// ReviewedBy is left out until the report has been reviewed and OverrideID unless it was pinned
+	ReviewedBy string `json:"reviewed_by,omitempty"`
+	OverrideID int    `json:"override_id,omitempty"`
+	// Reports is the number of open reports of the same match, this one included
+	Reports   int       `json:"reports"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MappingOverride is a mapping pinned by an admin. The converter always prefers it to the mappings it saved or a search.
+type MappingOverride struct {
+	ID             int    `json:"id"`
+	SourcePlatform string `json:"source_platform"`
+	SourceID       string `json:"source_id"`
+	TargetPlatform string `json:"target_platform"`
+	TargetID       string `json:"target_id"`
+	CreatedBy      string `json:"created_by"`
+	UpdatedBy      string `json:"updated_by"`
+	// Reports is the number of reports resolved by pinning the override
+	Reports   int       `json:"reports"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TrackError is why a track wasnt found on a platform
+type TrackError struct {
+	// Reason is one of not_found, upstream_error or region_blocked
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// PlaylistConversion is a playlist (from the source platform) and its tracks found on every platform
+type PlaylistConversion struct {
+	Source   string            `json:"source"`
+	Playlist Playlist          `json:"playlist"`
+	Tracks   []TrackConversion `json:"tracks"`
+	Summary  *PlaylistSummary  `json:"summary"`
+	// Changes is what changed since the playlist was last converted. It is null when it hadnt been (or it was too long ago)
+	Changes *PlaylistChanges `json:"changes,omitempty"`
+	// TookMs is how long (in ms) the conversion took
+	TookMs int64 `json:"took_ms"`
+}
+
+// PlaylistChanges is what changed in a playlist since it was last converted
+type PlaylistChanges struct {
+	// Snapshot is the snapshot (see Playlist.Snapshot) of the playlist when it was last converted
+	Snapshot string `json:"snapshot"`
+	// Changed is false when the playlist hasnt changed, so the last conversion was returned as it was
+	Changed bool `json:"changed"`
+	// Added are the tracks added since. The tracks that were already there arent converted again
+	Added []SingleTrack `json:"added"`
+	// Removed are the tracks removed since
+	Removed []SingleTrack `json:"removed"`
+}
+
+// PlaylistSummary is the number of tracks of a playlist found (and missing) on each platform
+type PlaylistSummary struct {
+	Total int `json:"total"`
+	// Complete is the number of tracks found on every platform
+	Complete int            `json:"complete"`
+	Found    map[string]int `json:"found"`
+	Missing  map[string]int `json:"missing"`
+}
+
+// Conversion is a track (the source) found on every platform. It is what the v2 API returns for a track, over REST and
+// the websocket.
+type Conversion struct {
+	Source *SingleTrack `json:"source"`
+	// Platforms is the result of the conversion on each platform (the source platform included), keyed by the platform's name.
+	Platforms map[string]*PlatformResult `json:"platforms"`
+}
+
+// PlatformResult is the result of a conversion on a platform. Track and Match are null when the track wasnt found and
+// Error is why.
+type PlatformResult struct {
+	Track *SingleTrack `json:"track"`
+	Match *Match       `json:"match"`
+	Error *TrackError  `json:"error"`
+}
+
+// Match is how a track was found on a platform
+type Match struct {
+	// Strategy is either "source" (it is the track converted), "isrc", "search" or "album"
+	Strategy string `json:"strategy"`
+	// Confidence (0-1) is how sure we are that the track is the same as the source track
+	Confidence float64 `json:"confidence"`
+	// Alternatives are the runner up search results, best first.
+	Alternatives []SingleTrack `json:"alternatives,omitempty"`
+}
+
+// PlaylistResult is what the v2 API returns for a playlist
+type PlaylistResult struct {
+	Source string `json:"source"`
+	// Playlist is the playlist on the source platform, without its tracks (they are the source of each conversion)
+	Playlist Playlist `json:"playlist"`
+	// Tracks is the conversion of each track, in the order of the playlist
+	Tracks  []Conversion     `json:"tracks"`
+	Summary *PlaylistSummary `json:"summary"`
+	Changes *PlaylistChanges `json:"changes,omitempty"`
+	TookMs  int64            `json:"took_ms"`
+}
+
+// ConversionResult is the response of the v2 API. Type is either "track", "playlist", "album" or "artist" and only the
+// field of the type is set.
+type ConversionResult struct {
+	Version  string            `json:"version"`
+	Type     string            `json:"type"`
+	Track    *Conversion       `json:"track,omitempty"`
+	Playlist *PlaylistResult   `json:"playlist,omitempty"`
+	Album    *AlbumConversion  `json:"album,omitempty"`
+	Artist   *ArtistConversion `json:"artist,omitempty"`
+}
+
+// BatchResult is the result of converting many links at once. Results has the result of each link, keyed by the link
+// as it was sent.
+type BatchResult struct {
+	Version string                `json:"version"`
+	Results map[string]*BatchItem `json:"results"`
+	TookMs  int64                 `json:"took_ms"`
+}
+
+// BatchItem is the result of converting one of the links of a batch. Error is set (instead of Result) when it failed
+type BatchItem struct {
+	Result *ConversionResult `json:"result"`
+	Error  *SocketError      `json:"error"`
+}
+
+// StreamMeta is the data of the meta event of a conversion stream. Playlist (without its tracks) is only set for playlists.
+type StreamMeta struct {
+	Version  string    `json:"version"`
+	Type     string    `json:"type"`
+	Source   string    `json:"source"`
+	Playlist *Playlist `json:"playlist,omitempty"`
+}
+
+// StreamTrack is the data of the track event of a playlist conversion stream. Index is the position of the track in the
+// playlist.
+type StreamTrack struct {
+	Index int         `json:"index"`
+	Track *Conversion `json:"track"`
+}
+
+// Job is a conversion run in the background (see the jobs package). Status is either "queued", "running", "done",
+// "failed" or "cancelled". Result is set once it is done and Error once it has failed.
+type Job struct {
+	ID        string            `json:"id"`
+	Status    string            `json:"status"`
+	URL       string            `json:"url"`
+	Type      string            `json:"type"`
+	Progress  JobProgress       `json:"progress"`
+	Result    *ConversionResult `json:"result"`
+	Error     *SocketError      `json:"error"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	// CallbackURL is where the job is sent (see the webhooks package) once it is done or has failed. ClientID is the
+	// client whose secret signs it.
+	CallbackURL string `json:"callback_url,omitempty"`
+	ClientID    string `json:"client_id,omitempty"`
+}
+
+// WebhookPayload is the body of a webhook. Event is either "job.done" or "job.failed"
+type WebhookPayload struct {
+	Event  string    `json:"event"`
+	Job    *Job      `json:"job"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// WebhookDelivery is an attempt at delivering a webhook. StatusCode is 0 when the request failed without a response
+type WebhookDelivery struct {
+	ClientID   string
+	JobID      string
+	URL        string
+	Event      string
+	Attempt    int
+	StatusCode int
+	Success    bool
+	Error      string
+}
+
+// JobProgress is the number of tracks of a job converted so far
+type JobProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// SocketRequest is a message sent by a client over the v2 websocket (see docs/websocket.md). Type is either "convert",
+// "create_playlist" or "cancel".
+type SocketRequest struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	URL     string `json:"url,omitempty"`
+	Payload struct {
+		Title    string   `json:"title"`
+		Tracks   []string `json:"tracks"`
+		Platform string   `json:"platform"`
+	} `json:"payload,omitempty"`
+}
+
+// SocketFrame is a message sent to a client over the v2 websocket. ID is the ID of the request it is for and Type is
+// either "progress", "result" or "error".
+type SocketFrame struct {
+	ID      string       `json:"id"`
+	Type    string       `json:"type"`
+	Payload interface{}  `json:"payload,omitempty"`
+	Error   *SocketError `json:"error,omitempty"`
+}
+
+// SocketError is the error of an error frame
+type SocketError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// SocketProgress is the payload of a progress frame
+type SocketProgress struct {
+	// Stage is either "resolving", "converting", "creating", "playlist" (Playlist is set), "track" (Index and Track are
+	// set) or "summary" (Summary is set)
+	Stage    string           `json:"stage"`
+	Playlist *Playlist        `json:"playlist,omitempty"`
+	Index    *int             `json:"index,omitempty"`
+	Track    *Conversion      `json:"track,omitempty"`
+	Summary  *PlaylistSummary `json:"summary,omitempty"`
+}
+
+// Album is an album on a platform
+type Album struct {
+	Title        string        `json:"title"`
+	Artistes     []string      `json:"artistes"`
+	URL          string        `json:"url"`
+	Cover        string        `json:"cover"`
+	ReleaseDate  string        `json:"release_date"`
+	UPC          string        `json:"upc"`
+	TracksNumber int           `json:"tracks_number"`
+	Duration     int           `json:"duration"`
+	Explicit     bool          `json:"explicit"`
+	Tracks       []SingleTrack `json:"tracks,omitempty"`
+	Platform     string        `json:"platform"`
+	ID           string        `json:"id"`
+	// MatchStrategy is how the album was found when searched from another platform's album. Either "upc" or "search".
+	MatchStrategy string `json:"match_strategy,omitempty"`
+	// Confidence (0-1) is how sure we are that a searched album is the same as the album searched for.
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// AlbumConversion is an album found on every platform from the album on one (the source).
+type AlbumConversion struct {
+	Source string `json:"source"`
+	// Albums is the album on each platform (without its tracks). It is null for a platform that doesnt have the album.
+	Albums map[string]*Album `json:"albums"`
+	// Tracks has, for each track of the source album, the same track on each platform. It is null for a platform when
+	// the track isnt on the platform's album.
+	Tracks []map[string]*SingleTrack `json:"tracks"`
+}
+
+// Artist is an artiste on a platform
+type Artist struct {
+	Name      string        `json:"name"`
+	URL       string        `json:"url"`
+	Picture   string        `json:"picture"`
+	Fans      int           `json:"fans"`
+	Albums    int           `json:"albums_number,omitempty"`
+	Genres    []string      `json:"genres,omitempty"`
+	TopTracks []SingleTrack `json:"top_tracks,omitempty"`
+	Platform  string        `json:"platform"`
+	ID        string        `json:"id"`
+	// Confidence (0-1) is how sure we are that a searched artiste is the same as the artiste searched for.
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// ArtistConversion is an artiste found on every platform from the artiste on one (the source).
+type ArtistConversion struct {
+	Source string `json:"source"`
+	// Artists is the artiste on each platform (without the top tracks). It is null for a platform that doesnt have the artiste.
+	Artists map[string]*Artist `json:"artists"`
+	// TopTracks has, for each top track of the artiste on the source, the same track on each platform. It is null for a
+	// platform that doesnt have the track.
+	TopTracks []map[string]*SingleTrack `json:"top_tracks"`
 }
 
 type HostSpotifyCreatePlaylist struct {
@@ -145,6 +472,7 @@ type HostSpotifyTrack struct {
 		Name                 string `json:"name"`
 		ReleaseDate          string `json:"release_date"`
 		ReleaseDatePrecision string `json:"release_date_precision"`
+		TotalTracks          int    `json:"total_tracks"`
 		Type                 string `json:"type"`
 		URI                  string `json:"uri"`
 	} `json:"album"`
@@ -204,73 +532,13 @@ type DeezerPlaylistCreationResponse struct {
 
 type HostSpotifySearchTrack struct {
 	Tracks struct {
-		Href  string `json:"href"`
-		Items []struct {
-			Album struct {
-				AlbumType string `json:"album_type"`
-				Artists   []struct {
-					ExternalUrls struct {
-						Spotify string `json:"spotify"`
-					} `json:"external_urls"`
-					Href string `json:"href"`
-					ID   string `json:"id"`
-					Name string `json:"name"`
-					Type string `json:"type"`
-					URI  string `json:"uri"`
-				} `json:"artists"`
-				AvailableMarkets []string `json:"available_markets"`
-				ExternalUrls     struct {
-					Spotify string `json:"spotify"`
-				} `json:"external_urls"`
-				Href   string `json:"href"`
-				ID     string `json:"id"`
-				Images []struct {
-					Height int    `json:"height"`
-					URL    string `json:"url"`
-					Width  int    `json:"width"`
-				} `json:"images"`
-				Name                 string `json:"name"`
-				ReleaseDate          string `json:"release_date"`
-				ReleaseDatePrecision string `json:"release_date_precision"`
-				TotalTracks          int    `json:"total_tracks"`
-				Type                 string `json:"type"`
-				URI                  string `json:"uri"`
-			} `json:"album"`
-			Artists []struct {
-				ExternalUrls struct {
-					Spotify string `json:"spotify"`
-				} `json:"external_urls"`
-				Href string `json:"href"`
-				ID   string `json:"id"`
-				Name string `json:"name"`
-				Type string `json:"type"`
-				URI  string `json:"uri"`
-			} `json:"artists"`
-			AvailableMarkets []string `json:"available_markets"`
-			DiscNumber       int      `json:"disc_number"`
-			DurationMs       int      `json:"duration_ms"`
-			Explicit         bool     `json:"explicit"`
-			ExternalIds      struct {
-				Isrc string `json:"isrc"`
-			} `json:"external_ids"`
-			ExternalUrls struct {
-				Spotify string `json:"spotify"`
-			} `json:"external_urls"`
-			Href        string `json:"href"`
-			ID          string `json:"id"`
-			IsLocal     bool   `json:"is_local"`
-			Name        string `json:"name"`
-			Popularity  int    `json:"popularity"`
-			PreviewURL  string `json:"preview_url"`
-			TrackNumber int    `json:"track_number"`
-			Type        string `json:"type"`
-			URI         string `json:"uri"`
-		} `json:"items"`
-		Limit    int         `json:"limit"`
-		Next     interface{} `json:"next"`
-		Offset   int         `json:"offset"`
-		Previous interface{} `json:"previous"`
-		Total    int         `json:"total"`
+		Href     string             `json:"href"`
+		Items    []HostSpotifyTrack `json:"items"`
+		Limit    int                `json:"limit"`
+		Next     interface{}        `json:"next"`
+		Offset   int                `json:"offset"`
+		Previous interface{}        `json:"previous"`
+		Total    int                `json:"total"`
 	} `json:"tracks"`
 }
 
@@ -562,46 +830,56 @@ type HostDeezerPlaylistResponse struct {
 	} `json:"creator"`
 	Type   string `json:"type"`
 	Tracks struct {
-		Data []struct {
-			ID                    int    `json:"id"`
-			Readable              bool   `json:"readable"`
-			Title                 string `json:"title"`
-			TitleShort            string `json:"title_short"`
-			TitleVersion          string `json:"title_version,omitempty"`
-			Link                  string `json:"link"`
-			Duration              int    `json:"duration"`
-			Rank                  int    `json:"rank"`
-			ExplicitLyrics        bool   `json:"explicit_lyrics"`
-			ExplicitContentLyrics int    `json:"explicit_content_lyrics"`
-			ExplicitContentCover  int    `json:"explicit_content_cover"`
-			Preview               string `json:"preview"`
-			Md5Image              string `json:"md5_image"`
-			TimeAdd               int    `json:"time_add"`
-			Artist                struct {
-				ID        int    `json:"id"`
-				Name      string `json:"name"`
-				Link      string `json:"link"`
-				Tracklist string `json:"tracklist"`
-				Type      string `json:"type"`
-			} `json:"artist"`
-			Album struct {
-				ID          int    `json:"id"`
-				Title       string `json:"title"`
-				Cover       string `json:"cover"`
-				CoverSmall  string `json:"cover_small"`
-				CoverMedium string `json:"cover_medium"`
-				CoverBig    string `json:"cover_big"`
-				CoverXl     string `json:"cover_xl"`
-				Md5Image    string `json:"md5_image"`
-				Tracklist   string `json:"tracklist"`
-				Type        string `json:"type"`
-			} `json:"album"`
-			Type string `json:"type"`
-		} `json:"data"`
-		Checksum string `json:"checksum"`
+		Data     []HostDeezerPlaylistTrack `json:"data"`
+		Checksum string                    `json:"checksum"`
 	} `json:"tracks"`
 }
 
+type HostDeezerPlaylistTrack struct {
+	ID                    int    `json:"id"`
+	Readable              bool   `json:"readable"`
+	Title                 string `json:"title"`
+	TitleShort            string `json:"title_short"`
+	TitleVersion          string `json:"title_version,omitempty"`
+	Link                  string `json:"link"`
+	Duration              int    `json:"duration"`
+	Rank                  int    `json:"rank"`
+	ExplicitLyrics        bool   `json:"explicit_lyrics"`
+	ExplicitContentLyrics int    `json:"explicit_content_lyrics"`
+	ExplicitContentCover  int    `json:"explicit_content_cover"`
+	Preview               string `json:"preview"`
+	Md5Image              string `json:"md5_image"`
+	TimeAdd               int    `json:"time_add"`
+	Artist                struct {
+		ID        int    `json:"id"`
+		Name      string `json:"name"`
+		Link      string `json:"link"`
+		Tracklist string `json:"tracklist"`
+		Type      string `json:"type"`
+	} `json:"artist"`
+	Album struct {
+		ID          int    `json:"id"`
+		Title       string `json:"title"`
+		Cover       string `json:"cover"`
+		CoverSmall  string `json:"cover_small"`
+		CoverMedium string `json:"cover_medium"`
+		CoverBig    string `json:"cover_big"`
+		CoverXl     string `json:"cover_xl"`
+		Md5Image    string `json:"md5_image"`
+		Tracklist   string `json:"tracklist"`
+		Type        string `json:"type"`
+	} `json:"album"`
+	Type string `json:"type"`
+}
+
+// HostDeezerPlaylistTracks is a page of the tracks of a deezer playlist (/playlist/ID/tracks)
+type HostDeezerPlaylistTracks struct {
+	Data     []HostDeezerPlaylistTrack `json:"data"`
+	Checksum string                    `json:"checksum"`
+	Total    int                       `json:"total"`
+	Next     string                    `json:"next"`
+}
+
 type HostSpotifyNewPlaylistCreationResponse struct {
 	Collaborative bool        `json:"collaborative"`
 	Description   interface{} `json:"description"`
@@ -647,3 +925,244 @@ type HostSpotifyAddNewPlaylistTracksResponse struct {
 type NewSpotifyPlaylist struct {
 	Name string `json:"name"`
 }
+
+type HostDeezerAlbum struct {
+	ID             int    `json:"id"`
+	Title          string `json:"title"`
+	Upc            string `json:"upc"`
+	Link           string `json:"link"`
+	Share          string `json:"share"`
+	Cover          string `json:"cover"`
+	CoverSmall     string `json:"cover_small"`
+	CoverMedium    string `json:"cover_medium"`
+	CoverBig       string `json:"cover_big"`
+	CoverXl        string `json:"cover_xl"`
+	Md5Image       string `json:"md5_image"`
+	Label          string `json:"label"`
+	NbTracks       int    `json:"nb_tracks"`
+	Duration       int    `json:"duration"`
+	Fans           int    `json:"fans"`
+	ReleaseDate    string `json:"release_date"`
+	RecordType     string `json:"record_type"`
+	Available      bool   `json:"available"`
+	Tracklist      string `json:"tracklist"`
+	ExplicitLyrics bool   `json:"explicit_lyrics"`
+	Contributors   []struct {
+		ID      int    `json:"id"`
+		Name    string `json:"name"`
+		Link    string `json:"link"`
+		Picture string `json:"picture"`
+		Type    string `json:"type"`
+		Role    string `json:"role"`
+	} `json:"contributors"`
+	Artist struct {
+		ID      int    `json:"id"`
+		Name    string `json:"name"`
+		Picture string `json:"picture"`
+		Type    string `json:"type"`
+	} `json:"artist"`
+	Type   string `json:"type"`
+	Tracks struct {
+		Data []struct {
+			ID                    int    `json:"id"`
+			Readable              bool   `json:"readable"`
+			Title                 string `json:"title"`
+			TitleShort            string `json:"title_short"`
+			TitleVersion          string `json:"title_version"`
+			Link                  string `json:"link"`
+			Duration              int    `json:"duration"`
+			Rank                  int    `json:"rank"`
+			ExplicitLyrics        bool   `json:"explicit_lyrics"`
+			ExplicitContentLyrics int    `json:"explicit_content_lyrics"`
+			ExplicitContentCover  int    `json:"explicit_content_cover"`
+			Preview               string `json:"preview"`
+			Md5Image              string `json:"md5_image"`
+			Artist                struct {
+				ID        int    `json:"id"`
+				Name      string `json:"name"`
+				Tracklist string `json:"tracklist"`
+				Type      string `json:"type"`
+			} `json:"artist"`
+			Type string `json:"type"`
+		} `json:"data"`
+	} `json:"tracks"`
+}
+
+type HostDeezerSearchAlbum struct {
+	Data []struct {
+		ID             int    `json:"id"`
+		Title          string `json:"title"`
+		Link           string `json:"link"`
+		Cover          string `json:"cover"`
+		CoverSmall     string `json:"cover_small"`
+		CoverMedium    string `json:"cover_medium"`
+		CoverBig       string `json:"cover_big"`
+		CoverXl        string `json:"cover_xl"`
+		Md5Image       string `json:"md5_image"`
+		GenreID        int    `json:"genre_id"`
+		NbTracks       int    `json:"nb_tracks"`
+		RecordType     string `json:"record_type"`
+		Tracklist      string `json:"tracklist"`
+		ExplicitLyrics bool   `json:"explicit_lyrics"`
+		Artist         struct {
+			ID        int    `json:"id"`
+			Name      string `json:"name"`
+			Link      string `json:"link"`
+			Picture   string `json:"picture"`
+			Tracklist string `json:"tracklist"`
+			Type      string `json:"type"`
+		} `json:"artist"`
+		Type string `json:"type"`
+	} `json:"data"`
+	Total int `json:"total"`
+}
+
+type HostSpotifyAlbum struct {
+	AlbumType string `json:"album_type"`
+	Artists   []struct {
+		ExternalUrls struct {
+			Spotify string `json:"spotify"`
+		} `json:"external_urls"`
+		Href string `json:"href"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+		URI  string `json:"uri"`
+	} `json:"artists"`
+	ExternalIds struct {
+		Upc string `json:"upc"`
+	} `json:"external_ids"`
+	ExternalUrls struct {
+		Spotify string `json:"spotify"`
+	} `json:"external_urls"`
+	Href   string `json:"href"`
+	ID     string `json:"id"`
+	Images []struct {
+		Height int    `json:"height"`
+		URL    string `json:"url"`
+		Width  int    `json:"width"`
+	} `json:"images"`
+	Label                string `json:"label"`
+	Name                 string `json:"name"`
+	Popularity           int    `json:"popularity"`
+	ReleaseDate          string `json:"release_date"`
+	ReleaseDatePrecision string `json:"release_date_precision"`
+	TotalTracks          int    `json:"total_tracks"`
+	Tracks               struct {
+		Href  string `json:"href"`
+		Items []struct {
+			Artists []struct {
+				ExternalUrls struct {
+					Spotify string `json:"spotify"`
+				} `json:"external_urls"`
+				Href string `json:"href"`
+				ID   string `json:"id"`
+				Name string `json:"name"`
+				Type string `json:"type"`
+				URI  string `json:"uri"`
+			} `json:"artists"`
+			DiscNumber   int  `json:"disc_number"`
+			DurationMs   int  `json:"duration_ms"`
+			Explicit     bool `json:"explicit"`
+			ExternalUrls struct {
+				Spotify string `json:"spotify"`
+			} `json:"external_urls"`
+			Href        string `json:"href"`
+			ID          string `json:"id"`
+			IsLocal     bool   `json:"is_local"`
+			Name        string `json:"name"`
+			PreviewURL  string `json:"preview_url"`
+			TrackNumber int    `json:"track_number"`
+			Type        string `json:"type"`
+			URI         string `json:"uri"`
+		} `json:"items"`
+		Limit  int    `json:"limit"`
+		Next   string `json:"next"`
+		Offset int    `json:"offset"`
+		Total  int    `json:"total"`
+	} `json:"tracks"`
+	Type string `json:"type"`
+	URI  string `json:"uri"`
+}
+
+type HostSpotifySearchAlbum struct {
+	Albums struct {
+		Href   string             `json:"href"`
+		Items  []HostSpotifyAlbum `json:"items"`
+		Limit  int                `json:"limit"`
+		Offset int                `json:"offset"`
+		Total  int                `json:"total"`
+	} `json:"albums"`
+}
+
+type HostDeezerArtist struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Link          string `json:"link"`
+	Share         string `json:"share"`
+	Picture       string `json:"picture"`
+	PictureSmall  string `json:"picture_small"`
+	PictureMedium string `json:"picture_medium"`
+	PictureBig    string `json:"picture_big"`
+	PictureXl     string `json:"picture_xl"`
+	NbAlbum       int    `json:"nb_album"`
+	NbFan         int    `json:"nb_fan"`
+	Radio         bool   `json:"radio"`
+	Tracklist     string `json:"tracklist"`
+	Type          string `json:"type"`
+}
+
+type HostDeezerSearchArtist struct {
+	Data  []HostDeezerArtist `json:"data"`
+	Total int                `json:"total"`
+}
+
+type HostDeezerArtistTopTracks struct {
+	Data  []HostDeezerTrack `json:"data"`
+	Total int               `json:"total"`
+}
+
+// HostDeezerError is the body deezer responds with when a request fails, e.g {"error": {"type": "DataException",
+// "message": "no data", "code": 800}}
+type HostDeezerError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+type HostSpotifyArtist struct {
+	ExternalUrls struct {
+		Spotify string `json:"spotify"`
+	} `json:"external_urls"`
+	Followers struct {
+		Total int `json:"total"`
+	} `json:"followers"`
+	Genres []string `json:"genres"`
+	Href   string   `json:"href"`
+	ID     string   `json:"id"`
+	Images []struct {
+		Height int    `json:"height"`
+		URL    string `json:"url"`
+		Width  int    `json:"width"`
+	} `json:"images"`
+	Name       string `json:"name"`
+	Popularity int    `json:"popularity"`
+	Type       string `json:"type"`
+	URI        string `json:"uri"`
+}
+
+type HostSpotifySearchArtist struct {
+	Artists struct {
+		Href   string              `json:"href"`
+		Items  []HostSpotifyArtist `json:"items"`
+		Limit  int                 `json:"limit"`
+		Offset int                 `json:"offset"`
+		Total  int                 `json:"total"`
+	} `json:"artists"`
+}
+
+type HostSpotifyArtistTopTracks struct {
+	Tracks []HostSpotifyTrack `json:"tracks"`
+}
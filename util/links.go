@@ -0,0 +1,83 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"zoove/errors"
+	"zoove/types"
+)
+
+// linkPattern is the shape of a link (or URI) of a platform. pattern has a "type" and an "id" group
+type linkPattern struct {
+	host    string
+	pattern *regexp.Regexp
+}
+
+// linkPatterns are all the link shapes we know. The type matched is checked against linkTypes after matching so that
+// links of types we dont support (e.g spotify podcasts) return UnsupportedLinkType instead of InvalidLink
+var linkPatterns = []linkPattern{
+	// https://open.spotify.com/track/ID, /intl-fr/track/ID, /embed/track/ID, /user/USER/playlist/ID and play.spotify.com
+	{host: HostSpotify, pattern: regexp.MustCompile(`^https?://(?:open|play)\.spotify\.com/(?:intl-[a-z]{2}(?:-[a-zA-Z]{2})?/)?(?:embed/)?(?:user/[^/]+/)?(?P<type>[a-z]+)/(?P<id>[A-Za-z0-9]+)/?(?:[?#].*)?$`)},
+	// spotify:track:ID and spotify:user:USER:playlist:ID
+	{host: HostSpotify, pattern: regexp.MustCompile(`^spotify:(?:user:[^:]+:)?(?P<type>[a-z]+):(?P<id>[A-Za-z0-9]+)$`)},
+	// https://www.deezer.com/track/ID, /en/track/ID, /us/album/ID, /fr/artist/ID/top_track and m.deezer.com
+	{host: HostDeezer, pattern: regexp.MustCompile(`^https?://(?:www\.|m\.)?deezer\.com/(?:[a-z]{2}(?:-[a-z]{2})?/)?(?P<type>[a-z]+)/(?P<id>\d+)(?:/[^?#]*)?(?:[?#].*)?$`)},
+	// https://widget.deezer.com/widget/dark/track/ID
+	{host: HostDeezer, pattern: regexp.MustCompile(`^https?://widget\.deezer\.com/widget/(?:[a-z]+/)?(?P<type>[a-z]+)/(?P<id>\d+)/?(?:[?#].*)?$`)},
+	// https://api.deezer.com/track/ID
+	{host: HostDeezer, pattern: regexp.MustCompile(`^https?://api\.deezer\.com/(?:[0-9.]+/)?(?P<type>[a-z]+)/(?P<id>\d+)/?(?:[?#].*)?$`)},
+}
+
+// platformHosts are the hosts of the platforms. A link from one of them that doesnt match any pattern is an InvalidLink
+var platformHosts = regexp.MustCompile(`(?i)(^|\.)(spotify|deezer)\.com$`)
+
+// linkTypes are the types of links we can convert
+var linkTypes = map[string]bool{"track": true, "album": true, "artist": true, "playlist": true}
+
+// ParseLink returns the platform, type and id of a link. It returns a *errors.LinkError when the link is not one we can convert.
+func ParseLink(link string) (*types.ExtractedInfo, error) {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return nil, &errors.LinkError{Link: link, Err: errors.InvalidLink}
+	}
+	// links copied from apps sometimes dont have the scheme
+	if !strings.Contains(link, "://") && !strings.HasPrefix(strings.ToLower(link), "spotify:") {
+		link = "https://" + link
+	}
+
+	for _, shape := range linkPatterns {
+		match := shape.pattern.FindStringSubmatch(link)
+		if match == nil {
+			continue
+		}
+		// the other groups in the patterns are non-capturing
+		linkType, id := match[1], match[2]
+		if !linkTypes[linkType] {
+			return nil, &errors.LinkError{Link: link, Err: errors.UnsupportedLinkType}
+		}
+		return &types.ExtractedInfo{Host: shape.host, Type: linkType, ID: id, URL: APIURL(shape.host, linkType, id)}, nil
+	}
+
+	if strings.HasPrefix(strings.ToLower(link), "spotify:") {
+		return nil, &errors.LinkError{Link: link, Err: errors.InvalidLink}
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return nil, &errors.LinkError{Link: link, Err: errors.InvalidLink}
+	}
+	if platformHosts.MatchString(parsed.Hostname()) {
+		return nil, &errors.LinkError{Link: link, Err: errors.InvalidLink}
+	}
+	return nil, &errors.LinkError{Link: link, Err: errors.UnsupportedPlatform}
+}
+
+// APIURL returns the API url of the track, album, artist or playlist with the id on the platform
+func APIURL(host, linkType, id string) string {
+	if host == HostSpotify {
+		return fmt.Sprintf("%s/v1/%ss/%s", os.Getenv("SPOTIFY_API_BASE"), linkType, id)
+	}
+	return fmt.Sprintf("%s/%s/%s", os.Getenv("DEEZER_API_BASE"), linkType, id)
+}
@@ -0,0 +1,152 @@
+package util
+
+import (
+	goerrors "errors"
+	"os"
+	"testing"
+	"zoove/errors"
+)
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		link     string
+		host     string
+		linkType string
+		id       string
+		err      error
+	}{
+		// spotify
+		{link: "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=a1b2c3d4e5f6", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC/", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "http://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "  https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC  ", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "https://open.spotify.com/intl-fr/track/4uLU6hMCjMI75M1A2tKUQC", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "https://open.spotify.com/intl-pt-BR/track/4uLU6hMCjMI75M1A2tKUQC?si=xyz", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "https://open.spotify.com/embed/track/4uLU6hMCjMI75M1A2tKUQC", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "https://open.spotify.com/embed/playlist/37i9dQZF1DXcBWIGoYBM5M?utm_source=generator", host: HostSpotify, linkType: "playlist", id: "37i9dQZF1DXcBWIGoYBM5M"},
+		{link: "https://play.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M", host: HostSpotify, linkType: "playlist", id: "37i9dQZF1DXcBWIGoYBM5M"},
+		{link: "https://open.spotify.com/user/spotify/playlist/37i9dQZF1DXcBWIGoYBM5M", host: HostSpotify, linkType: "playlist", id: "37i9dQZF1DXcBWIGoYBM5M"},
+		{link: "https://open.spotify.com/album/1DFixLWuPkv3KT3TnV35m3", host: HostSpotify, linkType: "album", id: "1DFixLWuPkv3KT3TnV35m3"},
+		{link: "https://open.spotify.com/intl-de/album/1DFixLWuPkv3KT3TnV35m3?si=abc", host: HostSpotify, linkType: "album", id: "1DFixLWuPkv3KT3TnV35m3"},
+		{link: "https://open.spotify.com/artist/3tVQdUvClmAT7URs9V3rsp", host: HostSpotify, linkType: "artist", id: "3tVQdUvClmAT7URs9V3rsp"},
+		{link: "https://open.spotify.com/artist/3tVQdUvClmAT7URs9V3rsp#top", host: HostSpotify, linkType: "artist", id: "3tVQdUvClmAT7URs9V3rsp"},
+		{link: "spotify:track:4uLU6hMCjMI75M1A2tKUQC", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "spotify:album:1DFixLWuPkv3KT3TnV35m3", host: HostSpotify, linkType: "album", id: "1DFixLWuPkv3KT3TnV35m3"},
+		{link: "spotify:artist:3tVQdUvClmAT7URs9V3rsp", host: HostSpotify, linkType: "artist", id: "3tVQdUvClmAT7URs9V3rsp"},
+		{link: "spotify:playlist:37i9dQZF1DXcBWIGoYBM5M", host: HostSpotify, linkType: "playlist", id: "37i9dQZF1DXcBWIGoYBM5M"},
+		{link: "spotify:user:spotify:playlist:37i9dQZF1DXcBWIGoYBM5M", host: HostSpotify, linkType: "playlist", id: "37i9dQZF1DXcBWIGoYBM5M"},
+		// links are only unescaped when they are read from the query, not again
+		{link: "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=a%zz+b", host: HostSpotify, linkType: "track", id: "4uLU6hMCjMI75M1A2tKUQC"},
+		{link: "https%3A%2F%2Fopen.spotify.com%2Ftrack%2F4uLU6hMCjMI75M1A2tKUQC", err: errors.InvalidLink},
+
+		// deezer
+		{link: "https://www.deezer.com/track/545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "https://www.deezer.com/en/track/545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "https://www.deezer.com/us/track/545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "https://www.deezer.com/fr/track/545820622?utm_source=deezer&utm_content=track-545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "https://www.deezer.com/en/track/545820622/", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "https://deezer.com/track/545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "http://www.deezer.com/track/545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "www.deezer.com/en/track/545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "https://m.deezer.com/track/545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "https://www.deezer.com/pt-br/track/545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "https://www.deezer.com/en/playlist/1479458365", host: HostDeezer, linkType: "playlist", id: "1479458365"},
+		{link: "https://www.deezer.com/playlist/1479458365?utm_campaign=clipboard-generic", host: HostDeezer, linkType: "playlist", id: "1479458365"},
+		{link: "https://www.deezer.com/en/album/103248", host: HostDeezer, linkType: "album", id: "103248"},
+		{link: "https://www.deezer.com/us/album/103248#disc1", host: HostDeezer, linkType: "album", id: "103248"},
+		{link: "https://www.deezer.com/en/artist/27", host: HostDeezer, linkType: "artist", id: "27"},
+		{link: "https://www.deezer.com/en/artist/27/top_track", host: HostDeezer, linkType: "artist", id: "27"},
+		{link: "https://widget.deezer.com/widget/dark/track/545820622", host: HostDeezer, linkType: "track", id: "545820622"},
+		{link: "https://widget.deezer.com/widget/auto/playlist/1479458365?tracklist=false", host: HostDeezer, linkType: "playlist", id: "1479458365"},
+		{link: "https://widget.deezer.com/widget/album/103248", host: HostDeezer, linkType: "album", id: "103248"},
+		{link: "https://api.deezer.com/track/3135556", host: HostDeezer, linkType: "track", id: "3135556"},
+		{link: "https://api.deezer.com/album/302127", host: HostDeezer, linkType: "album", id: "302127"},
+		{link: "https://api.deezer.com/2.0/artist/27", host: HostDeezer, linkType: "artist", id: "27"},
+
+		// unsupported types
+		{link: "https://open.spotify.com/episode/512ojhOuo1ktJprKbVcKyQ", err: errors.UnsupportedLinkType},
+		{link: "https://open.spotify.com/show/4rOoJ6Egrf8K2IrywzwOMk", err: errors.UnsupportedLinkType},
+		{link: "https://open.spotify.com/embed/episode/512ojhOuo1ktJprKbVcKyQ", err: errors.UnsupportedLinkType},
+		{link: "spotify:episode:512ojhOuo1ktJprKbVcKyQ", err: errors.UnsupportedLinkType},
+		{link: "https://open.spotify.com/user/spotify", err: errors.UnsupportedLinkType},
+		{link: "https://www.deezer.com/en/show/1234", err: errors.UnsupportedLinkType},
+		{link: "https://www.deezer.com/en/profile/12345", err: errors.UnsupportedLinkType},
+
+		// invalid links from the platforms
+		{link: "https://open.spotify.com/", err: errors.InvalidLink},
+		{link: "https://open.spotify.com/track/", err: errors.InvalidLink},
+		{link: "https://www.deezer.com/en/track/", err: errors.InvalidLink},
+		{link: "https://www.deezer.com/en/track/not-a-number", err: errors.InvalidLink},
+		{link: "https://www.deezer.com/en/", err: errors.InvalidLink},
+		{link: "spotify:track", err: errors.InvalidLink},
+		{link: "", err: errors.InvalidLink},
+		{link: "   ", err: errors.InvalidLink},
+		{link: "%zz", err: errors.InvalidLink},
+
+		// other platforms
+		{link: "https://music.apple.com/us/album/essence/1526470013?i=1526470016", err: errors.UnsupportedPlatform},
+		{link: "https://tidal.com/browse/track/145928452", err: errors.UnsupportedPlatform},
+		{link: "https://www.youtube.com/watch?v=dQw4w9WgXcQ", err: errors.UnsupportedPlatform},
+		{link: "https://notdeezer.com.evil.io/track/545820622", err: errors.UnsupportedPlatform},
+		{link: "https://open.spotify.com.evil.io/track/4uLU6hMCjMI75M1A2tKUQC", err: errors.UnsupportedPlatform},
+		{link: "hello world", err: errors.InvalidLink},
+	}
+
+	for _, test := range tests {
+		extracted, err := ExtractInfoMetadata(test.link)
+		if test.err != nil {
+			if !goerrors.Is(err, test.err) {
+				t.Errorf("%q: expected error %q, got %v", test.link, test.err, err)
+			}
+			var linkErr *errors.LinkError
+			if err != nil && !goerrors.As(err, &linkErr) {
+				t.Errorf("%q: expected a *errors.LinkError, got %T", test.link, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.link, err)
+			continue
+		}
+		if extracted.Host != test.host || extracted.Type != test.linkType || extracted.ID != test.id {
+			t.Errorf("%q: expected %s %s %s, got %s %s %s", test.link, test.host, test.linkType, test.id,
+				extracted.Host, extracted.Type, extracted.ID)
+		}
+	}
+}
+
+func TestAPIURL(t *testing.T) {
+	for key, value := range map[string]string{"SPOTIFY_API_BASE": "https://api.spotify.com", "DEEZER_API_BASE": "https://api.deezer.com"} {
+		previous, ok := os.LookupEnv(key)
+		os.Setenv(key, value)
+		defer func(key, previous string, ok bool) {
+			if ok {
+				os.Setenv(key, previous)
+				return
+			}
+			os.Unsetenv(key)
+		}(key, previous, ok)
+	}
+
+	tests := []struct {
+		host, linkType, id, expected string
+	}{
+		{HostSpotify, "track", "4uLU6hMCjMI75M1A2tKUQC", "https://api.spotify.com/v1/tracks/4uLU6hMCjMI75M1A2tKUQC"},
+		{HostSpotify, "playlist", "37i9dQZF1DXcBWIGoYBM5M", "https://api.spotify.com/v1/playlists/37i9dQZF1DXcBWIGoYBM5M"},
+		{HostSpotify, "album", "1DFixLWuPkv3KT3TnV35m3", "https://api.spotify.com/v1/albums/1DFixLWuPkv3KT3TnV35m3"},
+		{HostSpotify, "artist", "3tVQdUvClmAT7URs9V3rsp", "https://api.spotify.com/v1/artists/3tVQdUvClmAT7URs9V3rsp"},
+		{HostDeezer, "track", "3135556", "https://api.deezer.com/track/3135556"},
+		{HostDeezer, "playlist", "1479458365", "https://api.deezer.com/playlist/1479458365"},
+		{HostDeezer, "album", "302127", "https://api.deezer.com/album/302127"},
+		{HostDeezer, "artist", "27", "https://api.deezer.com/artist/27"},
+	}
+	for _, test := range tests {
+		if got := APIURL(test.host, test.linkType, test.id); got != test.expected {
+			t.Errorf("APIURL(%s, %s, %s): expected %s, got %s", test.host, test.linkType, test.id, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"zoove/cache"
+	"zoove/errors"
+)
+
+// DefaultShortHosts are the hosts of the share links of the platforms
+var DefaultShortHosts = []string{"deezer.page.link", "link.deezer.com", "spotify.link", "spotify.app.link", "spoti.fi"}
+
+const (
+	// DefaultMaxHops is the max number of redirects followed when resolving a short link
+	DefaultMaxHops = 5
+	// DefaultResolveTimeout is how long resolving a short link can take (all the hops)
+	DefaultResolveTimeout = 5 * time.Second
+	// DefaultResolvedLinkTTL is how long a resolved short link is cached. share links dont change
+	DefaultResolvedLinkTTL = 30 * 24 * time.Hour
+	// maxPageSize is the max size of a page read when looking for the canonical link
+	maxPageSize = 512 * 1024
+)
+
+// canonicalPatterns find the canonical link in the page of a short link that doesnt redirect (e.g spotify.link)
+var canonicalPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)<link[^>]+rel=["']canonical["'][^>]+href=["']([^"']+)["']`),
+	regexp.MustCompile(`(?i)<link[^>]+href=["']([^"']+)["'][^>]+rel=["']canonical["']`),
+	regexp.MustCompile(`(?i)<meta[^>]+property=["']og:url["'][^>]+content=["']([^"']+)["']`),
+	regexp.MustCompile(`(?i)<meta[^>]+content=["']([^"']+)["'][^>]+property=["']og:url["']`),
+}
+
+// LinkResolver resolves share (short) links to the links of the platforms. Backend is optional, resolved links are
+// cached in it when it is set.
+type LinkResolver struct {
+	Client     *http.Client
+	MaxHops    int
+	Timeout    time.Duration
+	ShortHosts []string
+	Backend    cache.Backend
+	CacheTTL   time.Duration
+}
+
+// NewLinkResolver returns a new LinkResolver with the default hops, timeout and short link hosts
+func NewLinkResolver(backend cache.Backend) *LinkResolver {
+	return &LinkResolver{
+		Client: &http.Client{
+			// we follow the redirects ourselves so that we can stop at the first link of a platform
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		MaxHops:    DefaultMaxHops,
+		Timeout:    DefaultResolveTimeout,
+		ShortHosts: DefaultShortHosts,
+		Backend:    backend,
+		CacheTTL:   DefaultResolvedLinkTTL,
+	}
+}
+
+// IsShortLink returns true if the link is a share link of a platform
+func (resolver *LinkResolver) IsShortLink(link string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, short := range resolver.ShortHosts {
+		if host == short {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the link of the platform a share link points to. Links that are not share links are returned as they
+// are. The link is not unescaped, links in a query are already unescaped when they are read.
+func (resolver *LinkResolver) Resolve(link string) (string, error) {
+	link = strings.TrimSpace(link)
+	if !resolver.IsShortLink(link) {
+		return link, nil
+	}
+
+	key := fmt.Sprintf("shortlink-%s", link)
+	if resolver.Backend != nil {
+		resolved, err := resolver.Backend.Get(key)
+		if err == nil {
+			return string(resolved), nil
+		}
+		if err != cache.ErrMiss {
+			log.Println("Error getting resolved short link from the cache")
+			log.Println(err)
+		}
+	}
+
+	resolved, err := resolver.follow(link)
+	if err != nil {
+		return "", err
+	}
+
+	if resolver.Backend != nil {
+		err := resolver.Backend.Set(key, []byte(resolved), resolver.CacheTTL)
+		if err != nil {
+			// not crucial. it'll just be resolved again
+			log.Println("Error caching resolved short link")
+			log.Println(err)
+		}
+	}
+	return resolved, nil
+}
+
+// allowed returns true if the link can be requested while following a short link, i.e it is a short link or a link of
+// a platform. Links to any other host (e.g an internal address) are not followed.
+func (resolver *LinkResolver) allowed(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	return resolver.IsShortLink(link) || platformHosts.MatchString(parsed.Hostname())
+}
+
+// follow follows the redirects of a short link (at most MaxHops) until it gets to a link of a platform
+func (resolver *LinkResolver) follow(link string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolver.Timeout)
+	defer cancel()
+
+	current := link
+	for hop := 0; hop <= resolver.MaxHops; hop++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, current, nil)
+		if err != nil {
+			return "", &errors.LinkError{Link: link, Err: errors.UnresolvableLink}
+		}
+		res, err := resolver.Client.Do(req)
+		if err != nil {
+			log.Printf("Error resolving short link %s\n", link)
+			log.Println(err)
+			return "", &errors.LinkError{Link: link, Err: errors.UnresolvableLink}
+		}
+
+		next := ""
+		if res.StatusCode >= 300 && res.StatusCode < 400 {
+			if location, err := res.Location(); err == nil {
+				next = location.String()
+			}
+		} else if res.StatusCode == http.StatusOK {
+			next = canonicalLink(res.Body, current)
+		}
+		res.Body.Close()
+
+		if next == "" {
+			return "", &errors.LinkError{Link: link, Err: errors.UnresolvableLink}
+		}
+		if _, err := ParseLink(next); err == nil {
+			return next, nil
+		}
+		// the page links to itself and it is not a link we know
+		if next == current {
+			return "", &errors.LinkError{Link: link, Err: errors.UnresolvableLink}
+		}
+		if !resolver.allowed(next) {
+			log.Printf("Short link %s redirects to %s. Not following it\n", link, next)
+			return "", &errors.LinkError{Link: link, Err: errors.UnresolvableLink}
+		}
+		current = next
+	}
+	return "", &errors.LinkError{Link: link, Err: errors.UnresolvableLink}
+}
+
+// canonicalLink returns the canonical link in a page (or an empty string if there is none)
+func canonicalLink(body io.Reader, pageURL string) string {
+	page, err := ioutil.ReadAll(io.LimitReader(body, maxPageSize))
+	if err != nil {
+		return ""
+	}
+	for _, pattern := range canonicalPatterns {
+		if match := pattern.FindSubmatch(page); match != nil {
+			canonical := strings.ReplaceAll(string(match[1]), "&amp;", "&")
+			base, err := url.Parse(pageURL)
+			if err != nil {
+				return canonical
+			}
+			reference, err := url.Parse(canonical)
+			if err != nil {
+				return ""
+			}
+			return base.ResolveReference(reference).String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+package util
+
+import (
+	goerrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+	"zoove/errors"
+)
+
+// newTestResolver returns a resolver that treats the test server as a short link host
+func newTestResolver(t *testing.T, server *httptest.Server) *LinkResolver {
+	parsed, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := NewLinkResolver(nil)
+	resolver.ShortHosts = []string{parsed.Hostname()}
+	resolver.Timeout = time.Second
+	return resolver
+}
+
+func TestLinkResolverResolve(t *testing.T) {
+	var requests int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/deezer", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Redirect(w, r, "/deezer/hop", http.StatusFound)
+	})
+	mux.HandleFunc("/deezer/hop", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Redirect(w, r, "https://www.deezer.com/en/track/545820622?utm_source=deezer", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/spotify", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><meta property="og:url" content="https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=abc&amp;context=x"></head></html>`)
+	})
+	mux.HandleFunc("/canonical", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `<html><head><link rel="canonical" href="https://open.spotify.com/album/1DFixLWuPkv3KT3TnV35m3"></head></html>`)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resolver := newTestResolver(t, server)
+
+	tests := []struct {
+		path     string
+		expected string
+		requests int32
+		err      error
+	}{
+		{path: "/deezer", expected: "https://www.deezer.com/en/track/545820622?utm_source=deezer", requests: 2},
+		{path: "/spotify", expected: "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=abc&context=x", requests: 1},
+		{path: "/canonical", expected: "https://open.spotify.com/album/1DFixLWuPkv3KT3TnV35m3", requests: 1},
+		// a link that redirects to itself is given up on right away
+		{path: "/loop", err: errors.UnresolvableLink, requests: 1},
+		{path: "/missing", err: errors.UnresolvableLink, requests: 0},
+	}
+
+	for _, test := range tests {
+		atomic.StoreInt32(&requests, 0)
+		resolved, err := resolver.Resolve(server.URL + test.path)
+		if test.err != nil {
+			if !goerrors.Is(err, test.err) {
+				t.Errorf("%s: expected error %q, got %v", test.path, test.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", test.path, err)
+		} else if resolved != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.path, test.expected, resolved)
+		}
+		if got := atomic.LoadInt32(&requests); got != test.requests {
+			t.Errorf("%s: expected %d requests, got %d", test.path, test.requests, got)
+		}
+	}
+}
+
+func TestLinkResolverMaxHops(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/1", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/2", http.StatusFound) })
+	mux.HandleFunc("/2", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/3", http.StatusFound) })
+	mux.HandleFunc("/3", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://www.deezer.com/album/103248", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resolver := newTestResolver(t, server)
+	resolver.MaxHops = 1
+	if _, err := resolver.Resolve(server.URL + "/1"); !goerrors.Is(err, errors.UnresolvableLink) {
+		t.Errorf("expected error %q, got %v", errors.UnresolvableLink, err)
+	}
+
+	resolver.MaxHops = 2
+	resolved, err := resolver.Resolve(server.URL + "/1")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if resolved != "https://www.deezer.com/album/103248" {
+		t.Errorf("expected the deezer album, got %s", resolved)
+	}
+}
+
+func TestLinkResolverTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		http.Redirect(w, r, "https://www.deezer.com/track/1", http.StatusFound)
+	}))
+	defer server.Close()
+
+	resolver := newTestResolver(t, server)
+	resolver.Timeout = 50 * time.Millisecond
+	if _, err := resolver.Resolve(server.URL + "/slow"); !goerrors.Is(err, errors.UnresolvableLink) {
+		t.Errorf("expected error %q, got %v", errors.UnresolvableLink, err)
+	}
+}
+
+func TestLinkResolverNotShortLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+	}))
+	defer server.Close()
+
+	resolver := newTestResolver(t, server)
+	link := "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC"
+	resolved, err := resolver.Resolve(link)
+	if err != nil || resolved != link {
+		t.Errorf("expected %s to be returned as it is, got %s (%v)", link, resolved, err)
+	}
+	if resolver.IsShortLink(link) {
+		t.Errorf("%s is not a short link", link)
+	}
+	for _, short := range []string{"https://deezer.page.link/abc", "https://link.deezer.com/s/30abc", "https://spotify.link/abc"} {
+		if !NewLinkResolver(nil).IsShortLink(short) {
+			t.Errorf("%s is a short link", short)
+		}
+	}
+}
+
+// hostRecorder is a http.RoundTripper that records the hosts requested
+type hostRecorder struct {
+	hosts []string
+}
+
+func (recorder *hostRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder.hosts = append(recorder.hosts, req.URL.Host)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestLinkResolverOnlyFollowsShortAndPlatformLinks(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	})
+	mux.HandleFunc("/escaped", func(w http.ResponseWriter, r *http.Request) {
+		// the link is requested as it was sent, not unescaped
+		if r.URL.EscapedPath() != "/escaped" || r.URL.RawQuery != "to=a%25b" {
+			t.Errorf("expected the link as it was sent, got %s", r.URL)
+		}
+		http.Redirect(w, r, "https://www.deezer.com/track/1", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resolver := newTestResolver(t, server)
+	recorder := &hostRecorder{}
+	resolver.Client.Transport = recorder
+	if _, err := resolver.Resolve(server.URL + "/internal"); !goerrors.Is(err, errors.UnresolvableLink) {
+		t.Errorf("expected error %q, got %v", errors.UnresolvableLink, err)
+	}
+	for _, host := range recorder.hosts {
+		if host == "169.254.169.254" {
+			t.Errorf("expected the internal address not to be requested")
+		}
+	}
+
+	resolved, err := resolver.Resolve(server.URL + "/escaped?to=a%25b")
+	if err != nil || resolved != "https://www.deezer.com/track/1" {
+		t.Errorf("expected the deezer track, got %s (%v)", resolved, err)
+	}
+}
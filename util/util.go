@@ -1,15 +1,15 @@
 package util
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	goerrors "errors"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
-	"os"
 	"strings"
 	"time"
+	"zoove/cache"
 	"zoove/errors"
 	"zoove/types"
 
@@ -30,8 +30,64 @@ const (
 	HostDeezerManageCommunityPermission     = "manage_community"
 	HostDeezerDeleteLibraryPermission       = "delete_library"
 	HostDeezerListeningHistoryPermission    = "listening_history"
+	// MatchStrategyISRC means a searched track was matched using its ISRC
+	MatchStrategyISRC = "isrc"
+	// MatchStrategySearch means a searched track was matched using a text search of its title and artiste
+	MatchStrategySearch = "search"
+	// MatchStrategyUPC means a searched album was matched using its UPC
+	MatchStrategyUPC = "upc"
+	// ReasonNotFound means a track wasnt found on a platform
+	ReasonNotFound = "not_found"
+	// ReasonUpstreamError means searching a platform for a track failed (e.g the platform is down or rate limiting us)
+	ReasonUpstreamError = "upstream_error"
+	// ReasonRegionBlocked means a track is on a platform but cant be played in our region
+	ReasonRegionBlocked = "region_blocked"
+	// MatchStrategyAlbum means an album track was matched with the tracks of the same album on another platform
+	MatchStrategyAlbum = "album"
+	// MatchStrategySource means the track is the one that was converted
+	MatchStrategySource = "source"
+	// MatchStrategyMapping means a track was matched using a mapping saved when it (or the track searched for) was converted before
+	MatchStrategyMapping = "mapping"
+	// MatchStrategyOverride means a track was matched using a mapping an admin pinned after it was reported as wrong
+	MatchStrategyOverride = "override"
+	// ErrorCodeInvalidRequest means a request couldnt be read (e.g it isnt JSON or has no ID)
+	ErrorCodeInvalidRequest = "invalid_request"
+	// ErrorCodeUnknownType means the type of a request isnt one we know
+	ErrorCodeUnknownType = "unknown_type"
+	// ErrorCodeDuplicateID means a request has the same ID as a request that is still running
+	ErrorCodeDuplicateID = "duplicate_id"
+	// ErrorCodeUnknownRequest means a request being cancelled isnt running
+	ErrorCodeUnknownRequest = "unknown_request"
+	// ErrorCodeTooManyRequests means a client has too many requests running at the same time
+	ErrorCodeTooManyRequests = "too_many_requests"
+	// ErrorCodeCancelled means a request was cancelled by the client
+	ErrorCodeCancelled = "cancelled"
+	// ErrorCodeInvalidLink means a link isnt a valid link
+	ErrorCodeInvalidLink = "invalid_link"
+	// ErrorCodeUnsupportedPlatform means a link isnt from a platform we support
+	ErrorCodeUnsupportedPlatform = "unsupported_platform"
+	// ErrorCodeUnsupportedLinkType means a link isnt a track, album, artist or playlist
+	ErrorCodeUnsupportedLinkType = "unsupported_link_type"
+	// ErrorCodeUnresolvableLink means a share link couldnt be resolved
+	ErrorCodeUnresolvableLink = "unresolvable_link"
+	// ErrorCodeUnauthorized means a request needs a user and the connection has none
+	ErrorCodeUnauthorized = "unauthorized"
+	// ErrorCodeInternal means something went wrong on our end
+	ErrorCodeInternal = "internal_error"
+	// APIVersion is the version of the API responses (see types.ConversionResult)
+	APIVersion = "2"
 )
 
+// IncrementSearches increments the number of searches made so far and returns it. It isnt crucial so errors are only logged
+func IncrementSearches(backend cache.Backend) int64 {
+	count, err := backend.Incr(RedisSearchesKey)
+	if err != nil {
+		log.Println("Error incrementing searches count")
+		log.Println(err)
+	}
+	return count
+}
+
 // RequestOk sends back a statusOk response to the client.
 func RequestOk(ctx *fiber.Ctx, data interface{}) error {
 	return ctx.Status(http.StatusOK).JSON(fiber.Map{"data": data, "message": "Resource found", "error": nil, "status": http.StatusOK})
@@ -47,6 +103,11 @@ func RequestUnAuthorized(ctx *fiber.Ctx, err error) error {
 	return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "The request you made is unauthorized", "error": err.Error(), "status": http.StatusUnauthorized, "data": nil})
 }
 
+// Forbidden sends back a statusForbidden to the client
+func Forbidden(ctx *fiber.Ctx, err error) error {
+	return ctx.Status(http.StatusForbidden).JSON(fiber.Map{"message": "You are not allowed to do this", "error": err.Error(), "status": http.StatusForbidden, "data": nil})
+}
+
 // RequestCreated sends back a statusCreated to the client
 func RequestCreated(ctx *fiber.Ctx, data interface{}) error {
 	return ctx.Status(http.StatusCreated).JSON(fiber.Map{"message": "The resource has been created", "error": nil, "status": http.StatusCreated, "data": data})
@@ -124,71 +185,32 @@ func ParseJwtToken(value, secret string) (*types.Token, error) {
 	return tk, nil
 }
 
-// ExtractInfoMetadata extracts metadata from URL
+// ExtractInfoMetadata extracts metadata from URL. It returns a *errors.LinkError when the URL is not one we can convert.
+// The URL is not unescaped again, links in a query are already unescaped when they are read from it.
 func ExtractInfoMetadata(rawURL string) (*types.ExtractedInfo, error) {
-	// rawURL := ctx.Query("track")
-	song, err := url.QueryUnescape(rawURL)
-
+	extracted, err := ParseLink(rawURL)
 	if err != nil {
-		log.Println("Error escaping URL")
+		log.Println("Oops! doesnt seem to be a valid playlist, album, artist or track URL")
 		return nil, err
 	}
-	parsedURL, err := url.Parse(song)
-	if err != nil {
-		log.Println("Error parsing URL")
-		return nil, err
-		// return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Error getting parsing the URL", "error": err.Error()})
-	}
-
-	platformHost := parsedURL.Host
-	index := strings.Index(song, "?")
-	sub := ""
-	if index == -1 {
-		sub = song
-	} else {
-		sub = song[:index]
-	}
-	extracted := &types.ExtractedInfo{}
-	// for deezer, a song is typically like this:A, https://www.deezer.com/en/track/545820622. but to
-	// use the API to get song info, its like this:B, https://api.deezer.com/track/3135556.
-	// the below code simply uses the url from A and turn it into B
-
-	if platformHost == "www.deezer.com" {
-		// find index of playlist
-		playlistIndex := strings.Index(sub, "playlist")
-		deezerID := ""
-		queryType := "track"
-		if playlistIndex != -1 {
-			deezerID = sub[playlistIndex+9:]
-			queryType = "playlist"
-		} else {
-			trackIndex := strings.Index(sub, "track")
-			deezerID = sub[trackIndex+6:]
+	return extracted, nil
+}
+
+// ShareReleaseDate sets the release date of tracks that dont have one to the release date of the first track that does.
+// This is because some platforms (deezer) dont return the release date of tracks in search results.
+func ShareReleaseDate(tracks ...*types.SingleTrack) {
+	releaseDate := ""
+	for _, track := range tracks {
+		if track != nil && track.ReleaseDate != "" {
+			releaseDate = track.ReleaseDate
+			break
 		}
-		extracted.Host = "deezer"
-		extracted.URL = fmt.Sprintf("%s/track/%s", os.Getenv("DEEZER_API_BASE"), deezerID)
-		extracted.ID = deezerID
-		extracted.Type = queryType
-	} else if platformHost == "open.spotify.com" {
-		playlistIndex := strings.Index(sub, "playlist")
-		spotifyID := ""
-		queryType := "track"
-		if playlistIndex != -1 {
-			spotifyID = sub[34:]
-			queryType = "playlist"
-		} else {
-			spotifyID = sub[31:]
+	}
+	for _, track := range tracks {
+		if track != nil && track.ReleaseDate == "" {
+			track.ReleaseDate = releaseDate
 		}
-
-		extracted.Host = "spotify"
-		extracted.URL = fmt.Sprintf("%s/v1/tracks/%s", os.Getenv("SPOTIFY_API_BASE"), spotifyID)
-		extracted.ID = spotifyID
-		extracted.Type = queryType
-	} else {
-		log.Println("Oops! doesnt seem to be a valid playlist or track URL")
-		extracted = &types.ExtractedInfo{}
 	}
-	return extracted, nil
 }
 
 // EncryptRefreshToken encrypts a refreshToken for a user
@@ -242,3 +264,26 @@ func MakeRequest(url string, src interface{}) error {
 
 	return nil
 }
+
+// ErrorCode returns the error code of an error sent to a client. Reasons (e.g not_found) are used as codes when they apply
+func ErrorCode(err error) string {
+	switch {
+	case goerrors.Is(err, context.Canceled):
+		return ErrorCodeCancelled
+	case goerrors.Is(err, errors.InvalidLink):
+		return ErrorCodeInvalidLink
+	case goerrors.Is(err, errors.UnsupportedPlatform):
+		return ErrorCodeUnsupportedPlatform
+	case goerrors.Is(err, errors.UnsupportedLinkType):
+		return ErrorCodeUnsupportedLinkType
+	case goerrors.Is(err, errors.UnresolvableLink):
+		return ErrorCodeUnresolvableLink
+	case goerrors.Is(err, errors.UnAuthorized):
+		return ErrorCodeUnauthorized
+	case goerrors.Is(err, errors.NotFound):
+		return ReasonNotFound
+	case goerrors.Is(err, errors.RegionBlocked):
+		return ReasonRegionBlocked
+	}
+	return ErrorCodeInternal
+}
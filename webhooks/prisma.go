@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"context"
+	"zoove/db"
+	"zoove/errors"
+	"zoove/types"
+)
+
+// PrismaStore gets the secrets of the clients and records the deliveries in the database
+type PrismaStore struct {
+	DB *db.PrismaClient
+}
+
+// NewPrismaStore returns a new PrismaStore
+func NewPrismaStore(client *db.PrismaClient) *PrismaStore {
+	return &PrismaStore{DB: client}
+}
+
+// Secret returns the secret of the client
+func (store *PrismaStore) Secret(ctx context.Context, clientID string) (string, error) {
+	client, err := store.DB.WebhookClient.FindOne(db.WebhookClient.ClientID.Equals(clientID)).Exec(ctx)
+	if err != nil {
+		if err == db.ErrNotFound {
+			return "", errors.NotFound
+		}
+		return "", err
+	}
+	return client.Secret, nil
+}
+
+// Record saves an attempt at delivering a webhook
+func (store *PrismaStore) Record(ctx context.Context, delivery *types.WebhookDelivery) error {
+	_, err := store.DB.WebhookDelivery.CreateOne(
+		db.WebhookDelivery.ClientID.Set(delivery.ClientID),
+		db.WebhookDelivery.JobID.Set(delivery.JobID),
+		db.WebhookDelivery.URL.Set(delivery.URL),
+		db.WebhookDelivery.Event.Set(delivery.Event),
+		db.WebhookDelivery.Attempt.Set(delivery.Attempt),
+		db.WebhookDelivery.StatusCode.Set(delivery.StatusCode),
+		db.WebhookDelivery.Success.Set(delivery.Success),
+		db.WebhookDelivery.Error.Set(delivery.Error),
+	).Exec(ctx)
+	return err
+}
@@ -0,0 +1,182 @@
+// Package webhooks tells clients about their jobs by POSTing to their callback URLs. Each request is signed with the
+// client's secret (HMAC-SHA256 of the body, hex encoded, in the X-Zoove-Signature header) and failed requests are retried
+// with exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"zoove/types"
+)
+
+const (
+	// SignatureHeader is the header with the signature of the body
+	SignatureHeader = "X-Zoove-Signature"
+	// EventHeader is the header with the event of the webhook
+	EventHeader = "X-Zoove-Event"
+	// DefaultMaxAttempts is the max number of times a webhook is sent
+	DefaultMaxAttempts = 5
+	// DefaultBaseDelay is how long to wait before the first retry. It doubles after every retry
+	DefaultBaseDelay = 2 * time.Second
+	// DefaultMaxDelay is the longest wait between two retries
+	DefaultMaxDelay = time.Minute
+	// DefaultTimeout is how long a client has to reply to a webhook
+	DefaultTimeout = 10 * time.Second
+)
+
+// The events of a webhook
+const (
+	EventJobDone   = "job.done"
+	EventJobFailed = "job.failed"
+)
+
+// Secrets returns the secret of a client. It returns errors.NotFound when there is no such client
+type Secrets interface {
+	Secret(ctx context.Context, clientID string) (string, error)
+}
+
+// Recorder records the attempts at delivering webhooks
+type Recorder interface {
+	Record(ctx context.Context, delivery *types.WebhookDelivery) error
+}
+
+// Deliverer sends webhooks
+type Deliverer struct {
+	Client      *http.Client
+	Secrets     Secrets
+	Recorder    Recorder
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Sleep waits between retries. It is time.Sleep unless it is being tested
+	Sleep func(time.Duration)
+}
+
+// NewDeliverer returns a new Deliverer with the default retries
+func NewDeliverer(secrets Secrets, recorder Recorder) *Deliverer {
+	return &Deliverer{
+		Client:      &http.Client{Timeout: DefaultTimeout},
+		Secrets:     secrets,
+		Recorder:    recorder,
+		MaxAttempts: DefaultMaxAttempts,
+		BaseDelay:   DefaultBaseDelay,
+		MaxDelay:    DefaultMaxDelay,
+		Sleep:       time.Sleep,
+	}
+}
+
+// Sign returns the signature of a body with a secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Verify returns true if the signature is the signature of the body with the secret
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Delay returns how long to wait before the attempt (the first attempt is 1)
+func (deliverer *Deliverer) Delay(attempt int) time.Duration {
+	delay := deliverer.BaseDelay
+	for retry := 2; retry < attempt; retry++ {
+		delay *= 2
+		if delay >= deliverer.MaxDelay {
+			return deliverer.MaxDelay
+		}
+	}
+	return delay
+}
+
+// DeliverJob sends the job to its callback URL. The event depends on the status of the job. It returns an error when
+// the webhook couldnt be delivered after all the attempts (or wont ever be).
+func (deliverer *Deliverer) DeliverJob(ctx context.Context, job *types.Job) error {
+	event := EventJobDone
+	if job.Status != "done" {
+		event = EventJobFailed
+	}
+	return deliverer.Deliver(ctx, job.ClientID, job.CallbackURL, &types.WebhookPayload{Event: event, Job: job, SentAt: time.Now()})
+}
+
+// Deliver sends the payload to the URL, signed with the client's secret
+func (deliverer *Deliverer) Deliver(ctx context.Context, clientID, url string, payload *types.WebhookPayload) error {
+	secret, err := deliverer.Secrets.Secret(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	signature := Sign(secret, body)
+
+	jobID := ""
+	if payload.Job != nil {
+		jobID = payload.Job.ID
+	}
+	lastError := ""
+	for attempt := 1; attempt <= deliverer.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			deliverer.Sleep(deliverer.Delay(attempt))
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		delivery := &types.WebhookDelivery{ClientID: clientID, JobID: jobID, URL: url, Event: payload.Event, Attempt: attempt}
+		retry := deliverer.send(ctx, url, payload.Event, signature, body, delivery)
+		if err := deliverer.Recorder.Record(ctx, delivery); err != nil {
+			// not crucial. the webhook is still delivered
+			log.Println("Error recording webhook delivery")
+			log.Println(err)
+		}
+		if delivery.Success {
+			return nil
+		}
+		lastError = delivery.Error
+		if !retry {
+			break
+		}
+	}
+	return fmt.Errorf("webhook %s to %s was not delivered: %s", payload.Event, url, lastError)
+}
+
+// send sends a webhook once and fills in the outcome of the delivery. It returns false if there's no point retrying
+// (the client rejected the webhook).
+func (deliverer *Deliverer) send(ctx context.Context, url, event, signature string, body []byte, delivery *types.WebhookDelivery) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	req.Header.Set(EventHeader, event)
+
+	res, err := deliverer.Client.Do(req)
+	if err != nil {
+		log.Printf("Error sending webhook to %s\n", url)
+		log.Println(err)
+		delivery.Error = err.Error()
+		return true
+	}
+	res.Body.Close()
+
+	delivery.StatusCode = res.StatusCode
+	delivery.Success = res.StatusCode >= 200 && res.StatusCode < 300
+	if delivery.Success {
+		return false
+	}
+	delivery.Error = fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	// the other client errors mean the client doesnt want it, so it isnt retried
+	return res.StatusCode >= 500 || res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,158 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"zoove/errors"
+	"zoove/types"
+)
+
+type testSecrets map[string]string
+
+func (secrets testSecrets) Secret(ctx context.Context, clientID string) (string, error) {
+	secret, ok := secrets[clientID]
+	if !ok {
+		return "", errors.NotFound
+	}
+	return secret, nil
+}
+
+type testRecorder struct {
+	mutex      sync.Mutex
+	deliveries []types.WebhookDelivery
+}
+
+func (recorder *testRecorder) Record(ctx context.Context, delivery *types.WebhookDelivery) error {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.deliveries = append(recorder.deliveries, *delivery)
+	return nil
+}
+
+// newTestDeliverer returns a deliverer that records its waits instead of sleeping
+func newTestDeliverer(recorder *testRecorder, waits *[]time.Duration) *Deliverer {
+	deliverer := NewDeliverer(testSecrets{"bot": "s3cret"}, recorder)
+	deliverer.Sleep = func(delay time.Duration) {
+		*waits = append(*waits, delay)
+	}
+	return deliverer
+}
+
+func TestDeliverJob(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first two attempts fail
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Verify("s3cret", body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("invalid signature %s", r.Header.Get(SignatureHeader))
+		}
+		if r.Header.Get(EventHeader) != EventJobDone {
+			t.Errorf("expected event %s, got %s", EventJobDone, r.Header.Get(EventHeader))
+		}
+		payload := &types.WebhookPayload{}
+		if err := json.Unmarshal(body, payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Event != EventJobDone || payload.Job.ID != "job-1" {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	recorder := &testRecorder{}
+	waits := []time.Duration{}
+	deliverer := newTestDeliverer(recorder, &waits)
+	job := &types.Job{ID: "job-1", Status: "done", CallbackURL: server.URL, ClientID: "bot"}
+	if err := deliverer.DeliverJob(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(recorder.deliveries) != 3 {
+		t.Fatalf("expected 3 deliveries, got %d", len(recorder.deliveries))
+	}
+	for index, delivery := range recorder.deliveries {
+		if delivery.Attempt != index+1 || delivery.JobID != "job-1" || delivery.ClientID != "bot" {
+			t.Errorf("unexpected delivery %+v", delivery)
+		}
+	}
+	if recorder.deliveries[0].Success || recorder.deliveries[0].StatusCode != http.StatusBadGateway {
+		t.Errorf("expected the first delivery to fail with 502, got %+v", recorder.deliveries[0])
+	}
+	if !recorder.deliveries[2].Success || recorder.deliveries[2].StatusCode != http.StatusNoContent {
+		t.Errorf("expected the last delivery to succeed, got %+v", recorder.deliveries[2])
+	}
+	if len(waits) != 2 || waits[0] != DefaultBaseDelay || waits[1] != 2*DefaultBaseDelay {
+		t.Errorf("expected to wait %s then %s, waited %v", DefaultBaseDelay, 2*DefaultBaseDelay, waits)
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	tests := []struct {
+		status   int
+		attempts int
+	}{
+		{status: http.StatusInternalServerError, attempts: DefaultMaxAttempts},
+		{status: http.StatusTooManyRequests, attempts: DefaultMaxAttempts},
+		// the client doesnt want it so it isnt retried
+		{status: http.StatusGone, attempts: 1},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+		}))
+		recorder := &testRecorder{}
+		waits := []time.Duration{}
+		deliverer := newTestDeliverer(recorder, &waits)
+		job := &types.Job{ID: "job-1", Status: "failed", CallbackURL: server.URL, ClientID: "bot"}
+		if err := deliverer.DeliverJob(context.Background(), job); err == nil {
+			t.Errorf("%d: expected an error", test.status)
+		}
+		if len(recorder.deliveries) != test.attempts {
+			t.Errorf("%d: expected %d attempts, got %d", test.status, test.attempts, len(recorder.deliveries))
+		}
+		server.Close()
+	}
+}
+
+func TestDeliverUnknownClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected webhook for an unknown client")
+	}))
+	defer server.Close()
+
+	recorder := &testRecorder{}
+	waits := []time.Duration{}
+	deliverer := newTestDeliverer(recorder, &waits)
+	job := &types.Job{ID: "job-1", Status: "done", CallbackURL: server.URL, ClientID: "nobody"}
+	if err := deliverer.DeliverJob(context.Background(), job); !goerrors.Is(err, errors.NotFound) {
+		t.Errorf("expected error %q, got %v", errors.NotFound, err)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	deliverer := NewDeliverer(nil, nil)
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute}
+	for index, delay := range expected {
+		attempt := index + 2
+		if got := deliverer.Delay(attempt); got != delay {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, delay, got)
+		}
+	}
+}